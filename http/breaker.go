@@ -0,0 +1,437 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ifnotnil/x/http/httplog"
+)
+
+const (
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerOpenTimeout      = 30 * time.Second
+	DefaultBreakerHalfOpenRequests = 1
+	DefaultBreakerIdleTimeout      = 5 * time.Minute
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every request with [ErrCircuitOpen].
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through, that decide whether the circuit closes or opens again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned when a request is rejected by an open (or a saturated half-open) circuit.
+// It matches errors.Is(err, ErrCircuitOpen).
+type CircuitOpenError struct {
+	Key   string
+	State CircuitState
+	// OpenUntil is the time the circuit turns half-open. Zero when the circuit is half-open.
+	OpenUntil time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %q is %s", ErrCircuitOpen.Error(), e.Key, e.State)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// FailureCondition classifies the outcome of a request as a failure.
+type FailureCondition func(resp *http.Response, err error) bool
+
+// CircuitStateChangeFn is called on every circuit state transition.
+type CircuitStateChangeFn func(key string, from, to CircuitState)
+
+// NewCircuitBreakerRoundTripper returns a [http.RoundTripper] with a circuit breaker per host. A circuit opens after
+// consecutive failures, rejects the requests while open and, after the open timeout, lets probe requests through (half-open).
+// By default the transport errors, the timeouts and the 500, 502, 503, 504 responses are failures.
+func NewCircuitBreakerRoundTripper(next http.RoundTripper, opts ...BreakerOption) *CircuitBreakerRoundTripper {
+	rt := &CircuitBreakerRoundTripper{
+		next:             next,
+		failureThreshold: DefaultBreakerFailureThreshold,
+		openTimeout:      DefaultBreakerOpenTimeout,
+		halfOpenRequests: DefaultBreakerHalfOpenRequests,
+		statusCodes: []int{
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		transportErrors: true,
+		timeouts:        true,
+		key:             func(req *http.Request) string { return req.URL.Host },
+		idleTimeout:     DefaultBreakerIdleTimeout,
+		circuits:        map[string]*circuit{},
+		now:             time.Now,
+	}
+
+	for _, o := range opts {
+		o(rt)
+	}
+
+	return rt
+}
+
+type BreakerOption func(rt *CircuitBreakerRoundTripper)
+
+// WithBreakerFailureThreshold sets the number of consecutive failures that open the circuit.
+func WithBreakerFailureThreshold(n int) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.failureThreshold = max(n, 1)
+	}
+}
+
+// WithBreakerOpenTimeout sets how long the circuit stays open before it turns half-open.
+func WithBreakerOpenTimeout(d time.Duration) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.openTimeout = d
+	}
+}
+
+// WithBreakerHalfOpenRequests sets the number of the concurrent probe requests of a half-open circuit.
+// The circuit closes when all of them succeed, any failure opens it again.
+func WithBreakerHalfOpenRequests(n int) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.halfOpenRequests = max(n, 1)
+	}
+}
+
+// WithBreakerStatusCodes sets the response status codes that are failures.
+func WithBreakerStatusCodes(codes ...int) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.statusCodes = codes
+	}
+}
+
+// WithBreakerTransportErrors sets whether the transport errors (other than timeouts) are failures.
+func WithBreakerTransportErrors(enabled bool) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.transportErrors = enabled
+	}
+}
+
+// WithBreakerTimeouts sets whether the timeouts (deadline exceeded or net timeout errors) are failures.
+func WithBreakerTimeouts(enabled bool) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.timeouts = enabled
+	}
+}
+
+// WithBreakerFailureCondition replaces the status codes, transport errors and timeouts failure classification.
+func WithBreakerFailureCondition(c FailureCondition) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.failureCondition = c
+	}
+}
+
+// WithBreakerKey sets the function that selects the circuit of a request. Default is the request host.
+// The circuits of the keys that are not used for the idle timeout are dropped ([WithBreakerIdleTimeout]).
+func WithBreakerKey(fn func(req *http.Request) string) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.key = fn
+	}
+}
+
+// WithBreakerIdleTimeout sets how long a circuit with no requests is kept (default [DefaultBreakerIdleTimeout]).
+// A dropped circuit starts over closed, an open circuit is kept at least until its open timeout passes.
+// Zero keeps the circuits forever, which is fine only for a small set of keys.
+func WithBreakerIdleTimeout(d time.Duration) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.idleTimeout = max(d, 0)
+	}
+}
+
+// WithBreakerOnStateChange adds a callback that is called on every state transition. It is called synchronously,
+// from the request that caused the transition.
+func WithBreakerOnStateChange(fn CircuitStateChangeFn) BreakerOption {
+	return func(rt *CircuitBreakerRoundTripper) {
+		rt.onStateChange = append(rt.onStateChange, fn)
+	}
+}
+
+// WithBreakerLogger logs every state transition through the given [httplog.HTTPLogger].
+func WithBreakerLogger(logger *httplog.HTTPLogger) BreakerOption {
+	return WithBreakerOnStateChange(func(key string, from, to CircuitState) {
+		logger.LogEvent(
+			context.Background(),
+			"circuit breaker state change",
+			slog.String("key", key),
+			slog.String("from", from.String()),
+			slog.String("to", to.String()),
+		)
+	})
+}
+
+type CircuitBreakerRoundTripper struct {
+	next             http.RoundTripper
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenRequests int
+	statusCodes      []int
+	transportErrors  bool
+	timeouts         bool
+	failureCondition FailureCondition
+	key              func(req *http.Request) string
+	idleTimeout      time.Duration
+	onStateChange    []CircuitStateChangeFn
+	now              func() time.Time
+
+	mu           sync.Mutex
+	circuits     map[string]*circuit
+	lastEviction time.Time
+}
+
+func (rt *CircuitBreakerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := rt.key(req)
+	c := rt.circuit(key)
+	defer rt.releaseCircuit(c)
+
+	admitted, err := c.allow(rt, key)
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+
+	// the caller gave up, it says nothing about the downstream.
+	if err != nil && errors.Is(err, context.Canceled) && req.Context().Err() != nil {
+		c.release(admitted)
+		return resp, err
+	}
+
+	c.record(rt, key, admitted, rt.failed(resp, err))
+
+	return resp, err
+}
+
+// State returns the current state of the circuit of the given key (host).
+func (rt *CircuitBreakerRoundTripper) State(key string) CircuitState {
+	rt.mu.Lock()
+	c, exists := rt.circuits[key]
+	rt.mu.Unlock()
+
+	if !exists {
+		return CircuitClosed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// circuit returns the circuit of the key, that is held until [CircuitBreakerRoundTripper.releaseCircuit].
+func (rt *CircuitBreakerRoundTripper) circuit(key string) *circuit {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	now := rt.now()
+	rt.evictIdle(now)
+
+	c, exists := rt.circuits[key]
+	if !exists {
+		c = &circuit{}
+		rt.circuits[key] = c
+	}
+	c.refs++
+	c.lastUsed = now
+
+	return c
+}
+
+func (rt *CircuitBreakerRoundTripper) releaseCircuit(c *circuit) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	c.refs--
+	c.lastUsed = rt.now()
+}
+
+// evictIdle drops the circuits that are not held and not used for the idle timeout. It runs at most once per idle timeout.
+// rt.mu must be held.
+func (rt *CircuitBreakerRoundTripper) evictIdle(now time.Time) {
+	if rt.idleTimeout <= 0 || now.Sub(rt.lastEviction) < rt.idleTimeout {
+		return
+	}
+	rt.lastEviction = now
+
+	for key, c := range rt.circuits {
+		if c.refs == 0 && now.Sub(c.lastUsed) >= rt.idleTimeout && c.expired(now, rt.openTimeout) {
+			delete(rt.circuits, key)
+		}
+	}
+}
+
+func (rt *CircuitBreakerRoundTripper) failed(resp *http.Response, err error) bool {
+	if rt.failureCondition != nil {
+		return rt.failureCondition(resp, err)
+	}
+
+	if err != nil {
+		if isTimeout(err) {
+			return rt.timeouts
+		}
+		return rt.transportErrors
+	}
+
+	return slices.Contains(rt.statusCodes, resp.StatusCode)
+}
+
+func (rt *CircuitBreakerRoundTripper) notify(key string, from, to CircuitState) {
+	for _, fn := range rt.onStateChange {
+		fn(key, from, to)
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+type circuit struct {
+	// refs (the requests that hold the circuit) and lastUsed are guarded by the CircuitBreakerRoundTripper mutex.
+	refs     int
+	lastUsed time.Time
+
+	mu                sync.Mutex
+	state             CircuitState
+	failures          int
+	openedAt          time.Time
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+	// generation changes on every transition, so that late results of a previous state are ignored.
+	generation uint64
+}
+
+// admission is the circuit state (and generation) a request was let through in.
+type admission struct {
+	state      CircuitState
+	generation uint64
+}
+
+func (c *circuit) allow(rt *CircuitBreakerRoundTripper, key string) (admission, error) {
+	c.mu.Lock()
+
+	if c.state == CircuitOpen {
+		openUntil := c.openedAt.Add(rt.openTimeout)
+		if rt.now().Before(openUntil) {
+			c.mu.Unlock()
+			return admission{}, &CircuitOpenError{Key: key, State: CircuitOpen, OpenUntil: openUntil}
+		}
+
+		c.transition(CircuitHalfOpen, rt.now())
+		defer rt.notify(key, CircuitOpen, CircuitHalfOpen)
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.halfOpenInFlight+c.halfOpenSuccesses >= rt.halfOpenRequests {
+			c.mu.Unlock()
+			return admission{}, &CircuitOpenError{Key: key, State: CircuitHalfOpen}
+		}
+		c.halfOpenInFlight++
+	}
+
+	a := admission{state: c.state, generation: c.generation}
+	c.mu.Unlock()
+
+	return a, nil
+}
+
+func (c *circuit) release(a admission) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if a.state == CircuitHalfOpen && a.generation == c.generation {
+		c.halfOpenInFlight--
+	}
+}
+
+func (c *circuit) record(rt *CircuitBreakerRoundTripper, key string, a admission, failed bool) {
+	c.mu.Lock()
+
+	if a.generation != c.generation {
+		c.mu.Unlock()
+		return
+	}
+
+	from := c.state
+	switch {
+	case c.state == CircuitClosed && failed:
+		c.failures++
+		if c.failures >= rt.failureThreshold {
+			c.transition(CircuitOpen, rt.now())
+		}
+	case c.state == CircuitClosed:
+		c.failures = 0
+	case c.state == CircuitHalfOpen && failed:
+		c.transition(CircuitOpen, rt.now())
+	case c.state == CircuitHalfOpen:
+		c.halfOpenInFlight--
+		c.halfOpenSuccesses++
+		if c.halfOpenSuccesses >= rt.halfOpenRequests {
+			c.transition(CircuitClosed, rt.now())
+		}
+	}
+	to := c.state
+
+	c.mu.Unlock()
+
+	if from != to {
+		rt.notify(key, from, to)
+	}
+}
+
+// expired reports whether the circuit can start over closed, that is unless it is open and its open timeout has not passed.
+func (c *circuit) expired(now time.Time, openTimeout time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state != CircuitOpen || !now.Before(c.openedAt.Add(openTimeout))
+}
+
+func (c *circuit) transition(to CircuitState, now time.Time) {
+	c.state = to
+	c.generation++
+	c.failures = 0
+	c.halfOpenInFlight = 0
+	c.halfOpenSuccesses = 0
+	if to == CircuitOpen {
+		c.openedAt = now
+	}
+}
@@ -0,0 +1,301 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ifnotnil/x/http/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stateChange struct {
+	Key      string
+	From, To CircuitState
+}
+
+func TestCircuitBreakerRoundTripper(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var (
+		mu      sync.Mutex
+		results []attemptResult
+		calls   int
+		changes []stateChange
+	)
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		r := results[0]
+		results = results[1:]
+		calls++
+
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return &http.Response{StatusCode: r.Status, Body: http.NoBody, Request: req}, nil
+	})
+
+	rt := NewCircuitBreakerRoundTripper(
+		next,
+		WithBreakerFailureThreshold(2),
+		WithBreakerOpenTimeout(time.Minute),
+		WithBreakerOnStateChange(func(key string, from, to CircuitState) {
+			changes = append(changes, stateChange{Key: key, From: from, To: to})
+		}),
+	)
+	rt.now = func() time.Time { return now }
+
+	do := func(url string) error {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		require.NoError(t, err)
+		resp, err := rt.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	// a success resets the consecutive failures.
+	results = []attemptResult{{Status: 503}, {Status: 200}, {Status: 503}}
+	require.NoError(t, do("http://a.test/"))
+	require.NoError(t, do("http://a.test/"))
+	require.NoError(t, do("http://a.test/"))
+	assert.Equal(t, CircuitClosed, rt.State("a.test"))
+
+	// threshold reached.
+	results = []attemptResult{{Err: errTransport}}
+	require.ErrorIs(t, do("http://a.test/"), errTransport)
+	assert.Equal(t, CircuitOpen, rt.State("a.test"))
+
+	// open circuit rejects without calling next.
+	calls = 0
+	err := do("http://a.test/")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, "a.test", openErr.Key)
+	assert.Equal(t, now.Add(time.Minute), openErr.OpenUntil)
+	assert.Equal(t, 0, calls)
+
+	// the other hosts are not affected.
+	results = []attemptResult{{Status: 200}}
+	require.NoError(t, do("http://b.test/"))
+	assert.Equal(t, CircuitClosed, rt.State("b.test"))
+
+	// half-open probe fails and opens the circuit again.
+	now = now.Add(time.Minute)
+	results = []attemptResult{{Status: 500}}
+	require.NoError(t, do("http://a.test/"))
+	assert.Equal(t, CircuitOpen, rt.State("a.test"))
+
+	// half-open probe succeeds and closes the circuit.
+	now = now.Add(time.Minute)
+	results = []attemptResult{{Status: 200}}
+	require.NoError(t, do("http://a.test/"))
+	assert.Equal(t, CircuitClosed, rt.State("a.test"))
+
+	assert.Equal(t, []stateChange{
+		{Key: "a.test", From: CircuitClosed, To: CircuitOpen},
+		{Key: "a.test", From: CircuitOpen, To: CircuitHalfOpen},
+		{Key: "a.test", From: CircuitHalfOpen, To: CircuitOpen},
+		{Key: "a.test", From: CircuitOpen, To: CircuitHalfOpen},
+		{Key: "a.test", From: CircuitHalfOpen, To: CircuitClosed},
+	}, changes)
+}
+
+func TestCircuitBreakerHalfOpenLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/slow" {
+			close(started)
+			<-release
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}
+		return nil, errTransport
+	})
+
+	now := time.Now()
+	rt := NewCircuitBreakerRoundTripper(next, WithBreakerFailureThreshold(1), WithBreakerOpenTimeout(time.Second))
+	rt.now = func() time.Time { return now }
+
+	do := func(path string) error {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://a.test"+path, nil)
+		require.NoError(t, err)
+		_, err = rt.RoundTrip(req) //nolint:bodyclose // no body.
+		return err
+	}
+
+	require.ErrorIs(t, do("/"), errTransport)
+	assert.Equal(t, CircuitOpen, rt.State("a.test"))
+
+	now = now.Add(time.Second)
+
+	done := make(chan error)
+	go func() { done <- do("/slow") }()
+	<-started
+
+	// the single probe is in flight.
+	err := do("/")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, CircuitHalfOpen, openErr.State)
+
+	close(release)
+	require.NoError(t, <-done)
+	assert.Equal(t, CircuitClosed, rt.State("a.test"))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestCircuitBreakerFailureClassification(t *testing.T) {
+	tests := map[string]struct {
+		Options  []BreakerOption
+		Status   int
+		Err      error
+		Expected bool
+	}{
+		"default 503":                   {Status: 503, Expected: true},
+		"default 404":                   {Status: 404, Expected: false},
+		"default 429":                   {Status: 429, Expected: false},
+		"custom status codes":           {Options: []BreakerOption{WithBreakerStatusCodes(429)}, Status: 429, Expected: true},
+		"default transport error":       {Err: errTransport, Expected: true},
+		"transport errors disabled":     {Options: []BreakerOption{WithBreakerTransportErrors(false)}, Err: errTransport, Expected: false},
+		"default deadline":              {Err: context.DeadlineExceeded, Expected: true},
+		"default net timeout":           {Err: timeoutError{}, Expected: true},
+		"timeouts disabled":             {Options: []BreakerOption{WithBreakerTimeouts(false)}, Err: timeoutError{}, Expected: false},
+		"timeouts disabled, transport":  {Options: []BreakerOption{WithBreakerTimeouts(false)}, Err: errTransport, Expected: true},
+		"transport disabled, timeout":   {Options: []BreakerOption{WithBreakerTransportErrors(false)}, Err: timeoutError{}, Expected: true},
+		"custom condition":              {Options: []BreakerOption{WithBreakerFailureCondition(func(resp *http.Response, err error) bool { return resp.StatusCode == 418 })}, Status: 418, Expected: true},
+		"custom condition ignores 503s": {Options: []BreakerOption{WithBreakerFailureCondition(func(resp *http.Response, err error) bool { return resp.StatusCode == 418 })}, Status: 503, Expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rt := NewCircuitBreakerRoundTripper(nil, tc.Options...)
+			var resp *http.Response
+			if tc.Err == nil {
+				resp = &http.Response{StatusCode: tc.Status}
+			}
+			assert.Equal(t, tc.Expected, rt.failed(resp, tc.Err))
+		})
+	}
+}
+
+func TestCircuitBreakerCallerCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	})
+
+	rt := NewCircuitBreakerRoundTripper(next, WithBreakerFailureThreshold(1))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://a.test/", nil)
+	require.NoError(t, err)
+
+	_, err = rt.RoundTrip(req) //nolint:bodyclose // no body.
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, CircuitClosed, rt.State("a.test"))
+}
+
+func TestCircuitBreakerKeyAndLogger(t *testing.T) {
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	logger, buf := testLogger()
+
+	rt := NewCircuitBreakerRoundTripper(
+		next,
+		WithBreakerFailureThreshold(1),
+		WithBreakerKey(func(req *http.Request) string { return req.Header.Get("X-Tenant") }),
+		WithBreakerLogger(httplog.NewHTTPLogger(httplog.WithLogger(logger), httplog.WithLogInLevel(slog.LevelError))),
+	)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://a.test/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Tenant", "tenant-1")
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, CircuitOpen, rt.State("tenant-1"))
+	assert.Equal(t, CircuitClosed, rt.State("a.test"))
+	assert.JSONEq(t,
+		`{"level":"ERROR","msg":"circuit breaker state change","key":"tenant-1","from":"closed","to":"open"}`,
+		buf.String(),
+	)
+}
+
+func TestCircuitBreakerIdleEviction(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusOK
+		if req.URL.Path == "/fail" {
+			status = http.StatusBadGateway
+		}
+		return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+	})
+
+	rt := NewCircuitBreakerRoundTripper(
+		next,
+		WithBreakerFailureThreshold(1),
+		WithBreakerOpenTimeout(10*time.Minute),
+		WithBreakerIdleTimeout(time.Minute),
+		WithBreakerKey(func(req *http.Request) string { return req.URL.Path }),
+	)
+	rt.now = func() time.Time { return now }
+
+	do := func(path string) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://a.test"+path, nil)
+		require.NoError(t, err)
+		resp, err := rt.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+
+	for _, p := range []string{"/1", "/2", "/3", "/fail"} {
+		do(p)
+	}
+	assert.Len(t, rt.circuits, 4)
+	assert.Equal(t, CircuitOpen, rt.State("/fail"))
+
+	// the idle closed circuits are dropped, the open one is kept until its open timeout passes.
+	now = now.Add(time.Minute)
+	do("/4")
+	assert.Len(t, rt.circuits, 2)
+	assert.Equal(t, CircuitOpen, rt.State("/fail"))
+
+	now = now.Add(10 * time.Minute)
+	do("/4")
+	assert.Len(t, rt.circuits, 1)
+	assert.Equal(t, CircuitClosed, rt.State("/fail"))
+}
+
+func TestCircuitOpenError(t *testing.T) {
+	err := error(&CircuitOpenError{Key: "a.test", State: CircuitOpen})
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, `circuit breaker is open: "a.test" is open`, err.Error())
+	assert.Equal(t, "CircuitState(7)", CircuitState(7).String())
+}
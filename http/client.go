@@ -1,46 +1,279 @@
 package http
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/ifnotnil/x/http/compress"
+	"github.com/ifnotnil/x/http/httplog"
+)
+
+const (
+	DefaultDialTimeout           = 30 * time.Second
+	DefaultDialKeepAlive         = 30 * time.Second
+	DefaultMaxIdleConns          = 100
+	DefaultMaxIdleConnsPerHost   = http.DefaultMaxIdleConnsPerHost
+	DefaultIdleConnTimeout       = 90 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultExpectContinueTimeout = 1 * time.Second
 )
 
-func newDefaultDialer() *net.Dialer {
-	return &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
+// NewHTTPClient returns a new default http client.
+//
+// Deprecated: use [NewClient] with [WithClientTimeout].
+func NewHTTPClient(globalTimeout time.Duration) *http.Client {
+	return NewClient(WithClientTimeout(globalTimeout))
+}
+
+// NewClient returns an http client built from the given options. The round trippers are composed, from the outermost, as:
+// logger ([WithClientLogger]) -> middlewares ([WithClientMiddleware], in order of registration) -> compression ([WithClientCompression]) -> transport.
+// So the logger sees the decoded response bodies and every middleware (e.g. retries) wraps the whole decoding.
+func NewClient(opts ...ClientOption) *http.Client {
+	b := newClientBuilder(opts)
+
+	return &http.Client{
+		Timeout:   b.timeout,
+		Transport: b.roundTripper(),
+	}
+}
+
+// NewTransport returns the [http.Transport] built from the transport related options (dialer, TLS, pools, HTTP/2, proxy).
+func NewTransport(opts ...ClientOption) *http.Transport {
+	return newClientBuilder(opts).transport
+}
+
+type ClientOption func(b *clientBuilder)
+
+type clientBuilder struct {
+	timeout     time.Duration
+	dialer      *net.Dialer
+	transport   *http.Transport
+	base        http.RoundTripper
+	compression []compress.RoundTripperOption
+	compress    bool
+	logger      *httplog.HTTPLogger
+	middlewares []func(http.RoundTripper) http.RoundTripper
+}
+
+func newClientBuilder(opts []ClientOption) *clientBuilder {
+	b := &clientBuilder{
+		dialer: &net.Dialer{
+			Timeout:   DefaultDialTimeout,
+			KeepAlive: DefaultDialKeepAlive,
+		},
+		transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          DefaultMaxIdleConns,
+			MaxIdleConnsPerHost:   DefaultMaxIdleConnsPerHost,
+			IdleConnTimeout:       DefaultIdleConnTimeout,
+			TLSHandshakeTimeout:   DefaultTLSHandshakeTimeout,
+			ExpectContinueTimeout: DefaultExpectContinueTimeout,
+		},
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	b.transport.DialContext = b.dialer.DialContext
+
+	return b
+}
+
+func (b *clientBuilder) roundTripper() http.RoundTripper {
+	var rt http.RoundTripper = b.transport
+	if b.base != nil {
+		rt = b.base
+	}
+
+	if b.compress {
+		rt = compress.NewRoundTripper(rt, b.compression...)
+	}
+
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		rt = b.middlewares[i](rt)
+	}
+
+	if b.logger != nil {
+		rt = b.logger.LoggerRoundTripper(rt)
+	}
+
+	return rt
+}
+
+// WithClientTimeout sets the [http.Client] timeout, that covers the whole exchange including the response body read.
+func WithClientTimeout(d time.Duration) ClientOption {
+	return func(b *clientBuilder) {
+		b.timeout = d
+	}
+}
+
+// WithDialer sets the connect timeout and the TCP keep alive period of the connections.
+func WithDialer(timeout, keepAlive time.Duration) ClientOption {
+	return func(b *clientBuilder) {
+		b.dialer.Timeout = timeout
+		b.dialer.KeepAlive = keepAlive
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the connections.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.TLSClientConfig = cfg
+	}
+}
+
+// WithTLSHandshakeTimeout sets the time to wait for the TLS handshake. Zero means no timeout.
+func WithTLSHandshakeTimeout(d time.Duration) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.TLSHandshakeTimeout = d
 	}
 }
 
-func newDefaultTransport() *http.Transport {
-	if t, is := http.DefaultTransport.(*http.Transport); is {
-		return &http.Transport{
-			Proxy:                 t.Proxy,
-			DialContext:           newDefaultDialer().DialContext,
-			ForceAttemptHTTP2:     t.ForceAttemptHTTP2,
-			MaxIdleConns:          t.MaxIdleConns,
-			IdleConnTimeout:       t.IdleConnTimeout,
-			TLSHandshakeTimeout:   t.TLSHandshakeTimeout,
-			ExpectContinueTimeout: t.ExpectContinueTimeout,
+// WithIdleConns sets the idle (keep-alive) connections pool: the maximum idle connections in total and per host,
+// and how long an idle connection is kept.
+func WithIdleConns(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.MaxIdleConns = maxIdle
+		b.transport.MaxIdleConnsPerHost = maxIdlePerHost
+		b.transport.IdleConnTimeout = idleTimeout
+	}
+}
+
+// WithMaxConnsPerHost limits the total (dialing, active and idle) connections per host. Zero means no limit.
+func WithMaxConnsPerHost(n int) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.MaxConnsPerHost = n
+	}
+}
+
+// WithResponseHeaderTimeout sets the time to wait for the response headers, after the request is written.
+func WithResponseHeaderTimeout(d time.Duration) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.ResponseHeaderTimeout = d
+	}
+}
+
+// WithExpectContinueTimeout sets the time to wait for the 100-continue response of an "Expect: 100-continue" request.
+func WithExpectContinueTimeout(d time.Duration) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.ExpectContinueTimeout = d
+	}
+}
+
+// WithHTTP2 enables HTTP/2 (the default) with the given tuning. A nil config keeps the net/http defaults.
+func WithHTTP2(cfg *http.HTTP2Config) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.ForceAttemptHTTP2 = true
+		b.transport.HTTP2 = cfg
+		b.transport.Protocols = nil
+	}
+}
+
+// WithoutHTTP2 restricts the transport to HTTP/1.1.
+func WithoutHTTP2() ClientOption {
+	return func(b *clientBuilder) {
+		p := &http.Protocols{}
+		p.SetHTTP1(true)
+
+		b.transport.ForceAttemptHTTP2 = false
+		b.transport.HTTP2 = nil
+		b.transport.Protocols = p
+	}
+}
+
+// WithProxy sets the function that selects the proxy of a request. Nil disables the proxy.
+// By default the proxy is selected from the environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY).
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.Proxy = proxy
+	}
+}
+
+// WithProxyURL sends every request through the given proxy, except the requests to the noProxy hosts.
+// A noProxy entry matches the host exactly, a ".domain.test" entry matches the domain and its sub domains
+// and "*" matches every host. An entry with a port (e.g. "localhost:8080" or ".domain.test:443") matches only
+// that port, the default port of the scheme is used for the urls without one.
+func WithProxyURL(proxyURL *url.URL, noProxy ...string) ClientOption {
+	return func(b *clientBuilder) {
+		b.transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL, noProxy) {
+				return nil, nil //nolint:nilnil // nil url means no proxy.
+			}
+
+			return proxyURL, nil
 		}
 	}
+}
 
-	return &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           newDefaultDialer().DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+func bypassProxy(u *url.URL, noProxy []string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
 	}
+
+	for _, np := range noProxy {
+		np = strings.ToLower(strings.TrimSpace(np))
+		if h, p, err := net.SplitHostPort(np); err == nil {
+			if p != port {
+				continue
+			}
+			np = h
+		}
+		np = strings.TrimSuffix(strings.TrimPrefix(np, "["), "]")
+
+		switch {
+		case np == "*":
+			return true
+		case strings.HasPrefix(np, "."):
+			if host == np[1:] || strings.HasSuffix(host, np) {
+				return true
+			}
+		case host == np:
+			return true
+		}
+	}
+
+	return false
 }
 
-// NewHTTPClient returns a new default http client.
-func NewHTTPClient(globalTimeout time.Duration) *http.Client {
-	return &http.Client{
-		Timeout:   globalTimeout,
-		Transport: newDefaultTransport(),
+// WithClientTransport replaces the base transport (e.g. with a test one). The transport related options are ignored.
+func WithClientTransport(rt http.RoundTripper) ClientOption {
+	return func(b *clientBuilder) {
+		b.base = rt
+	}
+}
+
+// WithClientCompression wraps the transport with a [compress.RoundTripper] of the given options.
+func WithClientCompression(opts ...compress.RoundTripperOption) ClientOption {
+	return func(b *clientBuilder) {
+		b.compress = true
+		b.compression = opts
+	}
+}
+
+// WithClientLogger logs the exchanges through the [httplog.HTTPLogger.LoggerRoundTripper], as the outermost round tripper.
+func WithClientLogger(logger *httplog.HTTPLogger) ClientOption {
+	return func(b *clientBuilder) {
+		b.logger = logger
+	}
+}
+
+// WithClientMiddleware adds a round tripper middleware between the logger and the compression.
+// The first registered middleware is the outermost one.
+func WithClientMiddleware(mw func(next http.RoundTripper) http.RoundTripper) ClientOption {
+	return func(b *clientBuilder) {
+		b.middlewares = append(b.middlewares, mw)
 	}
 }
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifnotnil/x/http/compress"
+	"github.com/ifnotnil/x/http/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		tr := NewTransport()
+		assert.True(t, tr.ForceAttemptHTTP2)
+		assert.Equal(t, DefaultMaxIdleConns, tr.MaxIdleConns)
+		assert.Equal(t, DefaultMaxIdleConnsPerHost, tr.MaxIdleConnsPerHost)
+		assert.Equal(t, DefaultIdleConnTimeout, tr.IdleConnTimeout)
+		assert.Equal(t, DefaultTLSHandshakeTimeout, tr.TLSHandshakeTimeout)
+		assert.Equal(t, DefaultExpectContinueTimeout, tr.ExpectContinueTimeout)
+		assert.NotNil(t, tr.Proxy)
+		assert.NotNil(t, tr.DialContext)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS13}
+		h2 := &http.HTTP2Config{MaxConcurrentStreams: 50}
+
+		tr := NewTransport(
+			WithTLSConfig(tlsCfg),
+			WithTLSHandshakeTimeout(3*time.Second),
+			WithIdleConns(10, 5, time.Minute),
+			WithMaxConnsPerHost(20),
+			WithResponseHeaderTimeout(4*time.Second),
+			WithExpectContinueTimeout(2*time.Second),
+			WithHTTP2(h2),
+			WithProxy(nil),
+		)
+
+		assert.Same(t, tlsCfg, tr.TLSClientConfig)
+		assert.Equal(t, 3*time.Second, tr.TLSHandshakeTimeout)
+		assert.Equal(t, 10, tr.MaxIdleConns)
+		assert.Equal(t, 5, tr.MaxIdleConnsPerHost)
+		assert.Equal(t, time.Minute, tr.IdleConnTimeout)
+		assert.Equal(t, 20, tr.MaxConnsPerHost)
+		assert.Equal(t, 4*time.Second, tr.ResponseHeaderTimeout)
+		assert.Equal(t, 2*time.Second, tr.ExpectContinueTimeout)
+		assert.Same(t, h2, tr.HTTP2)
+		assert.Nil(t, tr.Proxy)
+	})
+
+	t.Run("without http2", func(t *testing.T) {
+		tr := NewTransport(WithoutHTTP2())
+		assert.False(t, tr.ForceAttemptHTTP2)
+		require.NotNil(t, tr.Protocols)
+		assert.True(t, tr.Protocols.HTTP1())
+		assert.False(t, tr.Protocols.HTTP2())
+	})
+}
+
+func TestWithProxyURL(t *testing.T) {
+	proxyURL := &url.URL{Scheme: "http", Host: "proxy.test:3128"}
+	tr := NewTransport(WithProxyURL(proxyURL, "localhost", ".internal.test", "ported.test:8080", ".tls.test:443", "[::1]:9090"))
+
+	tests := map[string]struct {
+		URL      string
+		Expected *url.URL
+	}{
+		"proxied":        {URL: "https://domain.test/", Expected: proxyURL},
+		"exact host":     {URL: "http://localhost:8080/", Expected: nil},
+		"domain":         {URL: "https://internal.test/", Expected: nil},
+		"sub domain":     {URL: "https://api.INTERNAL.test/", Expected: nil},
+		"not sub domain": {URL: "https://notinternal.test/", Expected: proxyURL},
+		"port":           {URL: "http://ported.test:8080/", Expected: nil},
+		"other port":     {URL: "http://ported.test/", Expected: proxyURL},
+		"default port":   {URL: "https://api.tls.test/", Expected: nil},
+		"not tls port":   {URL: "http://api.tls.test/", Expected: proxyURL},
+		"ipv6 port":      {URL: "http://[::1]:9090/", Expected: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tc.URL, nil)
+			require.NoError(t, err)
+
+			got, err := tr.Proxy(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, got)
+		})
+	}
+
+	assert.True(t, bypassProxy(&url.URL{Scheme: "https", Host: "anything.test"}, []string{"*"}))
+}
+
+func TestNewClient(t *testing.T) {
+	srv := httptest.NewServer(compress.CompressMiddleware(compress.WithMinSize(1))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("hello ", 100)))
+	})))
+	defer srv.Close()
+
+	var order []string
+	middleware := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return httplog.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				resp, err := next.RoundTrip(req)
+				if err == nil {
+					// the compression is applied inside the middlewares.
+					assert.Empty(t, resp.Header.Get("Content-Encoding"))
+					assert.True(t, resp.Uncompressed)
+				}
+				return resp, err
+			})
+		}
+	}
+
+	logger, buf := testLogger()
+
+	c := NewClient(
+		WithClientTimeout(5*time.Second),
+		WithClientTransport(srv.Client().Transport),
+		WithClientCompression(compress.WithCompressionTypeGZIP(true)),
+		WithClientMiddleware(middleware("first")),
+		WithClientMiddleware(middleware("second")),
+		WithClientLogger(httplog.NewHTTPLogger(httplog.WithLogger(logger), httplog.WithLogInLevel(slog.LevelError))),
+	)
+	assert.Equal(t, 5*time.Second, c.Timeout)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, strings.Repeat("hello ", 100), string(body))
+	assert.Equal(t, []string{"first", "second"}, order)
+	assert.Contains(t, buf.String(), `"msg":"http outbound"`)
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	c := NewHTTPClient(time.Second)
+	assert.Equal(t, time.Second, c.Timeout)
+	_, is := c.Transport.(*http.Transport)
+	assert.True(t, is)
+}
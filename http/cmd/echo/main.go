@@ -0,0 +1,245 @@
+// Command echo serves the [xhttp.EchoHandler]: every request is answered with a json representation of itself.
+// It is meant for debugging proxies, gateways and clients.
+//
+// The response can be controlled by the request query:
+//
+//	/any/path?delay=250ms&status=503&header=Retry-After:%201
+//
+// Usage:
+//
+//	echo -addr :8443 -tls -log
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	xhttp "github.com/ifnotnil/x/http"
+	"github.com/ifnotnil/x/http/httplog"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stderr)
+	stop()
+
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type config struct {
+	addr         string
+	tls          bool
+	tlsCert      string
+	tlsKey       string
+	tlsHosts     string
+	h2c          bool
+	maxDelay     time.Duration
+	recoverer    bool
+	stackTrace   bool
+	log          bool
+	logLevel     slog.Level
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func parseFlags(args []string, output io.Writer) (config, error) {
+	cfg := config{}
+
+	fs := flag.NewFlagSet("echo", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cfg.addr, "addr", ":8080", "listen address")
+	fs.BoolVar(&cfg.tls, "tls", false, "serve TLS (and HTTP/2), with a self-signed certificate unless -tls-cert and -tls-key are set")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "", "TLS certificate (PEM) file, implies -tls")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "", "TLS private key (PEM) file, implies -tls")
+	fs.StringVar(&cfg.tlsHosts, "tls-hosts", "localhost,127.0.0.1,::1", "comma separated host names and IPs of the self-signed certificate")
+	fs.BoolVar(&cfg.h2c, "h2c", false, "serve unencrypted HTTP/2 (prior knowledge) next to HTTP/1")
+	fs.DurationVar(&cfg.maxDelay, "max-delay", 30*time.Second, "maximum delay that the delay query parameter can request")
+	fs.BoolVar(&cfg.recoverer, "recoverer", true, "enable the Recoverer middleware")
+	fs.BoolVar(&cfg.stackTrace, "stack-trace", false, "log the stack trace of the recovered panics")
+	fs.BoolVar(&cfg.log, "log", false, "log every request and response with the httplog middleware")
+	fs.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "log level (debug, info, warn, error)")
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", time.Minute, "server read timeout")
+	fs.DurationVar(&cfg.writeTimeout, "write-timeout", 0, "server write timeout, it should exceed -max-delay (0 means no timeout)")
+
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") {
+		return config{}, errors.New("both -tls-cert and -tls-key have to be set")
+	}
+
+	if cfg.tlsCert != "" {
+		cfg.tls = true
+	}
+
+	return cfg, nil
+}
+
+func run(ctx context.Context, args []string, output io.Writer) error {
+	cfg, err := parseFlags(args, output)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: cfg.logLevel}))
+
+	srv, err := newServer(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", cfg.addr)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.InfoContext(ctx, "echo server listening", slog.String("addr", ln.Addr().String()), slog.Bool("tls", cfg.tls))
+		if srv.TLSConfig != nil {
+			errCh <- srv.ServeTLS(ln, "", "")
+		} else {
+			errCh <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
+
+func newServer(cfg config, logger *slog.Logger) (*http.Server, error) {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(cfg.h2c)
+
+	srv := &http.Server{
+		Addr:              cfg.addr,
+		Handler:           newHandler(cfg, logger),
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       cfg.readTimeout,
+		WriteTimeout:      cfg.writeTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	if !cfg.tls {
+		return srv, nil
+	}
+
+	protocols.SetHTTP2(true)
+
+	var cert tls.Certificate
+	var err error
+	if cfg.tlsCert != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.tlsCert, cfg.tlsKey)
+	} else {
+		cert, err = selfSignedCertificate(strings.Split(cfg.tlsHosts, ","), time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	srv.TLSConfig = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	return srv, nil
+}
+
+// newHandler composes, from the outermost: httplog -> Recoverer -> EchoHandler.
+func newHandler(cfg config, logger *slog.Logger) http.Handler {
+	var h http.Handler = http.HandlerFunc(xhttp.EchoHandler(logger, xhttp.WithEchoQueryControl(cfg.maxDelay)))
+
+	if cfg.recoverer {
+		h = xhttp.Recoverer(logger, xhttp.WithRecovererStackTrace(cfg.stackTrace))(h)
+	}
+
+	if cfg.log {
+		h = httplog.NewHTTPLogger(httplog.WithLogger(logger), httplog.WithLogInLevel(cfg.logLevel)).Handler(h)
+	}
+
+	return h
+}
+
+// selfSignedCertificate generates an ECDSA P-256 certificate, valid for a year, for the given host names and IPs.
+func selfSignedCertificate(hosts []string, now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"echo self-signed"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, h := range hosts {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags(t *testing.T) {
+	cfg, err := parseFlags([]string{"-addr", ":9000", "-tls", "-log", "-log-level", "debug", "-max-delay", "2s"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.addr)
+	assert.True(t, cfg.tls)
+	assert.True(t, cfg.log)
+	assert.True(t, cfg.recoverer)
+	assert.Equal(t, slog.LevelDebug, cfg.logLevel)
+	assert.Equal(t, 2*time.Second, cfg.maxDelay)
+
+	_, err = parseFlags([]string{"-tls-cert", "cert.pem"}, io.Discard)
+	require.Error(t, err)
+
+	cfg, err = parseFlags([]string{"-tls-cert", "cert.pem", "-tls-key", "key.pem"}, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, cfg.tls)
+
+	_, err = parseFlags([]string{"-unknown"}, io.Discard)
+	require.Error(t, err)
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Now()
+	cert, err := selfSignedCertificate([]string{"localhost", " 127.0.0.1", ""}, now)
+	require.NoError(t, err)
+
+	require.NotNil(t, cert.Leaf)
+	assert.Equal(t, []string{"localhost"}, cert.Leaf.DNSNames)
+	require.Len(t, cert.Leaf.IPAddresses, 1)
+	assert.True(t, cert.Leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots, CurrentTime: now})
+	require.NoError(t, err)
+}
+
+func TestServerTLSAndHTTP2(t *testing.T) {
+	cfg, err := parseFlags([]string{"-tls", "-log", "-log-level", "error", "-max-delay", "10ms"}, io.Discard)
+	require.NoError(t, err)
+
+	logs := &bytes.Buffer{}
+	srv, err := newServer(cfg, slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelError})))
+	require.NoError(t, err)
+
+	ln, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() { _ = srv.ServeTLS(ln, "", "") }()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.TLSConfig.Certificates[0].Leaf)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		ForceAttemptHTTP2: true,
+	}}
+
+	url := "https://" + ln.Addr().String() + "/path?status=201&header=X-Echo:%20yes&delay=1h"
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "yes", resp.Header.Get("X-Echo"))
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "HTTP/2.0", body["proto"])
+	assert.Equal(t, "/path", body["url"].(map[string]any)["path"]) //nolint:forcetypeassert // test.
+}
@@ -0,0 +1,175 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentEncodingIdentity = "identity"
+	contentEncodingAny      = "*"
+)
+
+// EncodingPreference is a content coding with its weight (q-value) as used in the Accept-Encoding header.
+type EncodingPreference struct {
+	Encoding string
+	// Weight is the q-value in range [0, 1]. Zero means "not acceptable".
+	Weight float64
+}
+
+// Prefer returns an [EncodingPreference] of the given encoding and weight.
+func Prefer(encoding string, weight float64) EncodingPreference {
+	return EncodingPreference{Encoding: encoding, Weight: weight}
+}
+
+// NoIdentity returns the "identity;q=0" preference, that instructs the server to not respond with an unencoded body.
+func NoIdentity() EncodingPreference {
+	return EncodingPreference{Encoding: contentEncodingIdentity, Weight: 0}
+}
+
+func (e EncodingPreference) String() string {
+	if e.Weight >= 1 {
+		return e.Encoding
+	}
+
+	return e.Encoding + ";q=" + strconv.FormatFloat(math.Round(e.Weight*1000)/1000, 'f', -1, 64)
+}
+
+// EncodingPreferences is an ordered list of [EncodingPreference], the typed representation of an Accept-Encoding header.
+type EncodingPreferences []EncodingPreference
+
+// ParseAcceptEncoding parses the Accept-Encoding header values. Malformed items are ignored, codings are lower cased
+// and x-gzip is treated as gzip.
+func ParseAcceptEncoding(values ...string) EncodingPreferences {
+	var p EncodingPreferences
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			if e, ok := parseEncodingPreference(part); ok {
+				p = append(p, e)
+			}
+		}
+	}
+
+	return p
+}
+
+func parseEncodingPreference(part string) (EncodingPreference, bool) {
+	coding, params, _ := strings.Cut(part, ";")
+	coding = strings.ToLower(strings.TrimSpace(coding))
+	if coding == "" {
+		return EncodingPreference{}, false
+	}
+
+	if coding == "x-gzip" {
+		coding = contentEncodingGZIP
+	}
+
+	q := 1.0
+	for param := range strings.SplitSeq(params, ";") {
+		k, v, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || f < 0 || f > 1 {
+			return EncodingPreference{}, false
+		}
+		q = f
+	}
+
+	return EncodingPreference{Encoding: coding, Weight: q}, true
+}
+
+// String returns the Accept-Encoding header value.
+func (p EncodingPreferences) String() string {
+	s := make([]string, 0, len(p))
+	for _, e := range p {
+		s = append(s, e.String())
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// Weight returns the weight of the given encoding, falling back to the wildcard ("*") one.
+// The second value is false when the encoding is neither listed nor covered by a wildcard.
+func (p EncodingPreferences) Weight(encoding string) (float64, bool) {
+	wildcard, hasWildcard := 0.0, false
+	for _, e := range p {
+		switch {
+		case strings.EqualFold(e.Encoding, encoding):
+			return e.Weight, true
+		case e.Encoding == contentEncodingAny:
+			wildcard, hasWildcard = e.Weight, true
+		}
+	}
+
+	return wildcard, hasWildcard
+}
+
+// identityAcceptable reports whether an unencoded body is acceptable, which is the case unless "identity;q=0", or
+// "*;q=0" without an identity preference, is listed.
+func (p EncodingPreferences) identityAcceptable() bool {
+	q, listed := p.Weight(contentEncodingIdentity)
+	return !listed || q > 0
+}
+
+// Negotiate returns the supported encoding with the highest weight. When more than one supported encodings share the
+// highest weight, the order of the supported slice is used. Empty string is returned when none of the supported encodings
+// is acceptable (identity).
+func (p EncodingPreferences) Negotiate(supported []string) string {
+	selected := ""
+	selectedQ := 0.0
+	for _, s := range supported {
+		q, _ := p.Weight(s)
+		if q > selectedQ {
+			selected = s
+			selectedQ = q
+		}
+	}
+
+	return selected
+}
+
+// Validate checks the weights and that every acceptable encoding (weight > 0) is one of the supported ones.
+// identity is always valid, the wildcard only when it is not acceptable ("*;q=0").
+func (p EncodingPreferences) Validate(supported []string) error {
+	seen := make(map[string]struct{}, len(p))
+
+	for _, e := range p {
+		enc := strings.ToLower(e.Encoding)
+
+		if enc == "" || strings.ContainsAny(enc, ",; ") {
+			return fmt.Errorf("%w: malformed encoding %q", ErrInvalidEncodingPreferences, e.Encoding)
+		}
+
+		if e.Weight < 0 || e.Weight > 1 || math.IsNaN(e.Weight) {
+			return fmt.Errorf("%w: weight %v of %q is out of range [0, 1]", ErrInvalidEncodingPreferences, e.Weight, e.Encoding)
+		}
+
+		if _, exists := seen[enc]; exists {
+			return fmt.Errorf("%w: duplicate encoding %q", ErrInvalidEncodingPreferences, e.Encoding)
+		}
+		seen[enc] = struct{}{}
+
+		if e.Weight == 0 || enc == contentEncodingIdentity {
+			continue
+		}
+
+		if enc == contentEncodingAny {
+			return fmt.Errorf("%w: acceptable wildcard allows encodings with no registered decoder", ErrInvalidEncodingPreferences)
+		}
+
+		if !slices.Contains(supported, enc) {
+			return fmt.Errorf("%w: encoding %q has no registered decoder", ErrInvalidEncodingPreferences, e.Encoding)
+		}
+	}
+
+	return nil
+}
+
+var ErrInvalidEncodingPreferences = errors.New("invalid accept encoding preferences")
@@ -0,0 +1,151 @@
+package compress
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingPreferencesNegotiate(t *testing.T) {
+	supported := []string{"zstd", "br", "gzip", "deflate"}
+
+	tests := map[string]struct {
+		AcceptEncoding []string
+		Expected       string
+	}{
+		"empty":                 {AcceptEncoding: nil, Expected: ""},
+		"single":                {AcceptEncoding: []string{"gzip"}, Expected: "gzip"},
+		"server preference":     {AcceptEncoding: []string{"gzip, br, zstd"}, Expected: "zstd"},
+		"q values":              {AcceptEncoding: []string{"gzip;q=1.0, br;q=0.5, zstd;q=0.1"}, Expected: "gzip"},
+		"q zero":                {AcceptEncoding: []string{"zstd;q=0, gzip;q=0.2"}, Expected: "gzip"},
+		"multiple header lines": {AcceptEncoding: []string{"zstd;q=0", "br"}, Expected: "br"},
+		"case and spaces":       {AcceptEncoding: []string{"  GZip ; Q=0.8 ,  DEFLATE;q=0.9"}, Expected: "deflate"},
+		"x-gzip alias":          {AcceptEncoding: []string{"x-gzip"}, Expected: "gzip"},
+		"wildcard":              {AcceptEncoding: []string{"*"}, Expected: "zstd"},
+		"wildcard with exclude": {AcceptEncoding: []string{"zstd;q=0, br;q=0, *;q=0.5"}, Expected: "gzip"},
+		"wildcard zero":         {AcceptEncoding: []string{"*;q=0"}, Expected: ""},
+		"only identity":         {AcceptEncoding: []string{"identity"}, Expected: ""},
+		"unsupported":           {AcceptEncoding: []string{"compress, foo"}, Expected: ""},
+		"invalid q":             {AcceptEncoding: []string{"zstd;q=abc, gzip;q=2, br;q=0.3"}, Expected: "br"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, ParseAcceptEncoding(tc.AcceptEncoding...).Negotiate(supported))
+		})
+	}
+}
+
+func TestEncodingPreferencesString(t *testing.T) {
+	tests := map[string]struct {
+		Preferences EncodingPreferences
+		Expected    string
+	}{
+		"empty":       {Preferences: nil, Expected: ""},
+		"weight one":  {Preferences: EncodingPreferences{Prefer("zstd", 1), Prefer("gzip", 1)}, Expected: "zstd, gzip"},
+		"weights":     {Preferences: EncodingPreferences{Prefer("zstd", 1), Prefer("br", 0.8), Prefer("gzip", 0.25)}, Expected: "zstd, br;q=0.8, gzip;q=0.25"},
+		"rounding":    {Preferences: EncodingPreferences{Prefer("gzip", 0.12345)}, Expected: "gzip;q=0.123"},
+		"no identity": {Preferences: EncodingPreferences{Prefer("gzip", 1), NoIdentity()}, Expected: "gzip, identity;q=0"},
+		"parse round trip": {
+			Preferences: ParseAcceptEncoding("gzip;q=0.5, br", "identity;q=0"),
+			Expected:    "gzip;q=0.5, br, identity;q=0",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Preferences.String())
+		})
+	}
+}
+
+func TestEncodingPreferencesValidate(t *testing.T) {
+	supported := []string{"gzip", "br"}
+
+	tests := map[string]struct {
+		Preferences   EncodingPreferences
+		ExpectedError error
+	}{
+		"valid":                {Preferences: EncodingPreferences{Prefer("br", 1), Prefer("GZIP", 0.5), NoIdentity()}},
+		"unsupported excluded": {Preferences: EncodingPreferences{Prefer("gzip", 1), Prefer("zstd", 0)}},
+		"wildcard excluded":    {Preferences: EncodingPreferences{Prefer("gzip", 1), Prefer("*", 0)}},
+		"unsupported":          {Preferences: EncodingPreferences{Prefer("zstd", 1)}, ExpectedError: ErrInvalidEncodingPreferences},
+		"wildcard":             {Preferences: EncodingPreferences{Prefer("*", 0.1)}, ExpectedError: ErrInvalidEncodingPreferences},
+		"weight out of range":  {Preferences: EncodingPreferences{Prefer("gzip", 1.5)}, ExpectedError: ErrInvalidEncodingPreferences},
+		"negative weight":      {Preferences: EncodingPreferences{Prefer("gzip", -1)}, ExpectedError: ErrInvalidEncodingPreferences},
+		"duplicate":            {Preferences: EncodingPreferences{Prefer("gzip", 1), Prefer("gzip", 0.5)}, ExpectedError: ErrInvalidEncodingPreferences},
+		"malformed":            {Preferences: EncodingPreferences{Prefer("gzip, br", 1)}, ExpectedError: ErrInvalidEncodingPreferences},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.Preferences.Validate(supported)
+			if tc.ExpectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestRoundTripperAcceptEncoding(t *testing.T) {
+	tests := map[string]struct {
+		Options       []RoundTripperOption
+		Expected      string
+		ExpectedError error
+	}{
+		"default": {
+			Options:  nil,
+			Expected: "gzip, zstd, br",
+		},
+		"raw": {
+			Options:  []RoundTripperOption{WithAcceptEncoding("gzip;q=0.1"), WithCompressionTypeGZIP(true)},
+			Expected: "gzip;q=0.1",
+		},
+		"preferences": {
+			Options: []RoundTripperOption{
+				WithAcceptEncodingPreferences(Prefer("zstd", 1), Prefer("gzip", 0.5), NoIdentity()),
+				WithCompressionTypeGZIP(true),
+				WithCompressionTypeZSTD(true),
+			},
+			Expected: "zstd, gzip;q=0.5, identity;q=0",
+		},
+		"preferences without decoder": {
+			Options: []RoundTripperOption{
+				WithAcceptEncodingPreferences(Prefer("br", 1)),
+				WithCompressionTypeGZIP(true),
+			},
+			Expected:      "gzip",
+			ExpectedError: ErrInvalidEncodingPreferences,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sent *http.Request
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				sent = req
+				return okResponse(req), nil
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test/", nil)
+			require.NoError(t, err)
+
+			_, err = NewCheckedRoundTripper(next, tc.Options...)
+			if tc.ExpectedError != nil {
+				require.ErrorIs(t, err, tc.ExpectedError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// the invalid preferences fall back to the registered decoders.
+			_, err = NewRoundTripper(next, tc.Options...).RoundTrip(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, sent.Header.Get(AcceptEncoding))
+		})
+	}
+}
@@ -9,41 +9,95 @@ import (
 
 type BRBodyDecompressorPool struct {
 	readerPool sync.Pool
+	buffers    *readBufferPool
+	config     decompressorConfig
 }
 
-func NewBRBodyDecompressorPool() *BRBodyDecompressorPool {
+func NewBRBodyDecompressorPool(opts ...DecompressorOption) *BRBodyDecompressorPool {
+	config := newDecompressorConfig(opts)
 	return &BRBodyDecompressorPool{
+		config:     config,
+		buffers:    newReadBufferPool(config.br.BufferSize),
 		readerPool: sync.Pool{New: func() any { return &brotli.Reader{} }},
 	}
 }
 
 func (d *BRBodyDecompressorPool) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *BRBodyDecompressorPool) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	var buffered io.Reader
+
 	return &decompressorBodyWrapper[*brotli.Reader]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*brotli.Reader, error) {
+			buffered = d.buffers.get(compressedBody)
 			br, _ := d.readerPool.Get().(*brotli.Reader)
-			return br, br.Reset(compressedBody)
+			return br, br.Reset(buffered)
 		},
 		ReturnDecoderFn: func(decoder *brotli.Reader) error {
 			d.readerPool.Put(decoder)
+			d.buffers.put(buffered)
 			return nil
 		},
 	}
 }
 
-type BRBodyDecompressor struct{}
+type BRBodyDecompressor struct {
+	buffers *readBufferPool
+	config  decompressorConfig
+}
 
-func NewBRBodyDecompressor() *BRBodyDecompressor {
-	return &BRBodyDecompressor{}
+func NewBRBodyDecompressor(opts ...DecompressorOption) *BRBodyDecompressor {
+	config := newDecompressorConfig(opts)
+	return &BRBodyDecompressor{
+		config:  config,
+		buffers: newReadBufferPool(config.br.BufferSize),
+	}
 }
 
 func (d *BRBodyDecompressor) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *BRBodyDecompressor) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	var buffered io.Reader
+
 	return &decompressorBodyWrapper[*brotli.Reader]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*brotli.Reader, error) {
-			return brotli.NewReader(compressedBody), nil
+			buffered = d.buffers.get(compressedBody)
+			return brotli.NewReader(buffered), nil
 		},
 		ReturnDecoderFn: func(_ *brotli.Reader) error {
+			d.buffers.put(buffered)
+			return nil
+		},
+	}
+}
+
+type BRBodyCompressorPool struct {
+	writerPool sync.Pool
+}
+
+// NewBRBodyCompressorPool returns a pooled brotli [BodyEncoder] that uses the given quality level (0-11).
+func NewBRBodyCompressorPool(level int) *BRBodyCompressorPool {
+	return &BRBodyCompressorPool{
+		writerPool: sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, level) }},
+	}
+}
+
+func (c *BRBodyCompressorPool) WrapWriter(w io.Writer) EncodeWriter {
+	return &compressorWriterWrapper[*brotli.Writer]{
+		Writer: w,
+		GetEncoderFn: func(w io.Writer) (*brotli.Writer, error) {
+			br, _ := c.writerPool.Get().(*brotli.Writer)
+			br.Reset(w)
+			return br, nil
+		},
+		ReturnEncoderFn: func(encoder *brotli.Writer) error {
+			c.writerPool.Put(encoder)
 			return nil
 		},
 	}
@@ -18,6 +18,7 @@ type decompressorBodyWrapper[D Decompressor] struct {
 
 	stickyError error
 	decoder     D
+	ready       bool
 	onceInit    sync.Once
 	onceClose   sync.Once
 }
@@ -28,12 +29,19 @@ func (d *decompressorBodyWrapper[D]) initDecoder() {
 		d.decoder, err = d.GetDecoderFn(d.CompressedBody)
 		if err != nil {
 			d.stickyError = err
+			return
 		}
+		d.ready = true
 	})
 }
 
 func (d *decompressorBodyWrapper[D]) closeDecoder() {
 	d.onceClose.Do(func() {
+		// the decoder was never acquired (failed or not even read), there is nothing to close or return.
+		if !d.ready {
+			return
+		}
+
 		var dErr, rErr error
 
 		if cl, is := Decompressor(d.decoder).(io.Closer); is {
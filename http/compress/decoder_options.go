@@ -0,0 +1,163 @@
+package compress
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ZSTDDecoderOptions tunes the zstd decoders. The zero value keeps the defaults, that favor low memory usage.
+type ZSTDDecoderOptions struct {
+	// MaxWindow is the maximum window size a stream may use, streams with bigger windows are rejected. Zero means 128MiB.
+	MaxWindow uint64
+	// MaxMemory is the maximum memory a decoded stream may allocate. Zero means the library default (64GiB).
+	MaxMemory uint64
+	// Concurrency is the number of goroutines used to decode a stream. Zero means 1, negative means GOMAXPROCS.
+	// The [ZSTDBodyDecompressorPool] always uses 1, as the goroutines of the pooled decoders are never released, and any
+	// other value than 0 or 1 fails its bodies with [ErrZSTDPoolConcurrency].
+	Concurrency int
+	// DisableLowMem allocates the buffers upfront, trading memory for speed.
+	DisableLowMem bool
+	// Dictionaries are zstd format dictionaries, selected by the dictionary id of the frame header.
+	Dictionaries [][]byte
+}
+
+// decoderOptions returns the zstd options. minWindow raises the max window, used by the dictionary compressed streams.
+func (o ZSTDDecoderOptions) decoderOptions(minWindow uint64) []zstd.DOption {
+	maxWindow := o.MaxWindow
+	if maxWindow == 0 {
+		maxWindow = defaultZSTDDecoderMaxWindow
+	}
+
+	concurrency := o.Concurrency
+	switch {
+	case concurrency == 0:
+		concurrency = 1
+	case concurrency < 0:
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	opts := []zstd.DOption{
+		zstd.WithDecoderLowmem(!o.DisableLowMem),
+		zstd.WithDecoderMaxWindow(max(maxWindow, minWindow)),
+		zstd.WithDecoderConcurrency(concurrency),
+	}
+
+	if o.MaxMemory > 0 {
+		opts = append(opts, zstd.WithDecoderMaxMemory(o.MaxMemory))
+	}
+
+	if len(o.Dictionaries) > 0 {
+		opts = append(opts, zstd.WithDecoderDicts(o.Dictionaries...))
+	}
+
+	return opts
+}
+
+// ErrZSTDPoolConcurrency is returned by the [ZSTDBodyDecompressorPool] bodies when a concurrency other than 0 or 1 is set.
+var ErrZSTDPoolConcurrency = errors.New("the pooled zstd decoders support only concurrency 1")
+
+// pooled returns the options of the decoders that are kept in a pool, which are never closed.
+func (o ZSTDDecoderOptions) pooled() (ZSTDDecoderOptions, error) {
+	if o.Concurrency != 0 && o.Concurrency != 1 {
+		return o, fmt.Errorf("%w: got %d", ErrZSTDPoolConcurrency, o.Concurrency)
+	}
+
+	o.Concurrency = 1
+
+	return o, nil
+}
+
+// WithZSTDDecoderOptions tunes the zstd decoders. It is ignored by the rest of the decoders.
+func WithZSTDDecoderOptions(o ZSTDDecoderOptions) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.zstd = o
+	}
+}
+
+// GZIPDecoderOptions tunes the gzip decoders. The zero value keeps the defaults.
+type GZIPDecoderOptions struct {
+	// SingleStream stops decoding at the end of the first gzip member, instead of reading concatenated members.
+	SingleStream bool
+	// BufferSize is the read buffer size of the compressed body. Zero means the library default (4KiB).
+	BufferSize int
+}
+
+// WithGZIPDecoderOptions tunes the gzip decoders. It is ignored by the rest of the decoders.
+func WithGZIPDecoderOptions(o GZIPDecoderOptions) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.gzip = o
+	}
+}
+
+// FlateDecoderOptions tunes the deflate decoders. The zero value keeps the defaults.
+type FlateDecoderOptions struct {
+	// Dictionary is the preset dictionary the stream was compressed with.
+	Dictionary []byte
+	// BufferSize is the read buffer size of the compressed body. Zero means the library default (4KiB).
+	BufferSize int
+}
+
+// WithFlateDecoderOptions tunes the deflate decoders. It is ignored by the rest of the decoders.
+func WithFlateDecoderOptions(o FlateDecoderOptions) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.flate = o
+	}
+}
+
+// BRDecoderOptions tunes the brotli decoders. The zero value keeps the defaults.
+type BRDecoderOptions struct {
+	// BufferSize is the read buffer size of the compressed body. Zero means no extra buffering.
+	BufferSize int
+}
+
+// WithBRDecoderOptions tunes the brotli decoders. It is ignored by the rest of the decoders.
+func WithBRDecoderOptions(o BRDecoderOptions) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.br = o
+	}
+}
+
+// readBufferPool pools the read buffers of the compressed bodies. A nil pool does no buffering.
+type readBufferPool struct {
+	pool sync.Pool
+}
+
+func newReadBufferPool(size int) *readBufferPool {
+	if size <= 0 {
+		return nil
+	}
+
+	return &readBufferPool{
+		pool: sync.Pool{New: func() any { return bufio.NewReaderSize(nil, size) }},
+	}
+}
+
+// get returns r wrapped with a read buffer.
+func (p *readBufferPool) get(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+
+	br, _ := p.pool.Get().(*bufio.Reader)
+	br.Reset(r)
+
+	return br
+}
+
+// put returns the buffer (if any) that get returned.
+func (p *readBufferPool) put(r io.Reader) {
+	if p == nil {
+		return
+	}
+
+	if br, is := r.(*bufio.Reader); is {
+		br.Reset(nil)
+		p.pool.Put(br)
+	}
+}
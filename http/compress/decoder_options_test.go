@@ -0,0 +1,216 @@
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	kflate "github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func flateDictBytes(t *testing.T, dict, b []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w, err := kflate.NewWriterDict(buf, kflate.DefaultCompression, dict)
+	require.NoError(t, err)
+	_, err = w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, b []byte, opts ...zstd.EOption) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w, err := zstd.NewWriter(buf, opts...)
+	require.NoError(t, err)
+	_, err = w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestDecoderOptions(t *testing.T) {
+	original := []byte(strings.Repeat(`{"id": 42, "name": "john", "email": "john@domain.test"}`, 200))
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<17) // 2MiB
+
+	gz := compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), original)
+	twoMembers := append(append([]byte{}, gz...), compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), []byte("second member"))...)
+
+	flateDict := []byte(`{"id": 0, "name": "", "email": ""}`)
+
+	samples := make([][]byte, 0, 100)
+	for i := range 100 {
+		samples = append(samples, fmt.Appendf(nil, `{"id": %d, "name": "user-%d", "email": "user-%d@domain.test", "score": %d}`, i, i*7, i*13, i*i))
+	}
+	zstdDict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       1234,
+		Contents: samples,
+		History:  bytes.Join(samples[:20], nil),
+		Offsets:  [3]int{1, 4, 8},
+	})
+	require.NoError(t, err)
+
+	type decoderFactory func(opts ...DecompressorOption) BodyDecoder
+
+	gzipDecoders := map[string]decoderFactory{
+		"gzip":      func(opts ...DecompressorOption) BodyDecoder { return NewGZIPBodyDecompressor(opts...) },
+		"gzip pool": func(opts ...DecompressorOption) BodyDecoder { return NewGZIPBodyDecompressorPool(opts...) },
+	}
+	flateDecoders := map[string]decoderFactory{
+		"flate":      func(opts ...DecompressorOption) BodyDecoder { return NewFlateBodyDecompressor(opts...) },
+		"flate pool": func(opts ...DecompressorOption) BodyDecoder { return NewFlateBodyDecompressorPool(opts...) },
+	}
+	brDecoders := map[string]decoderFactory{
+		"br":      func(opts ...DecompressorOption) BodyDecoder { return NewBRBodyDecompressor(opts...) },
+		"br pool": func(opts ...DecompressorOption) BodyDecoder { return NewBRBodyDecompressorPool(opts...) },
+	}
+	zstdDecoders := map[string]decoderFactory{
+		"zstd":      func(opts ...DecompressorOption) BodyDecoder { return NewZSTDBodyDecompressor(opts...) },
+		"zstd pool": func(opts ...DecompressorOption) BodyDecoder { return NewZSTDBodyDecompressorPool(opts...) },
+	}
+
+	tests := map[string]struct {
+		Decoders      map[string]decoderFactory
+		Options       []DecompressorOption
+		Compressed    []byte
+		Expected      []byte
+		ExpectedError assert.ErrorAssertionFunc
+	}{
+		"gzip buffer size": {
+			Decoders:      gzipDecoders,
+			Options:       []DecompressorOption{WithGZIPDecoderOptions(GZIPDecoderOptions{BufferSize: 64 << 10})},
+			Compressed:    gz,
+			Expected:      original,
+			ExpectedError: assert.NoError,
+		},
+		"gzip multistream": {
+			Decoders:      gzipDecoders,
+			Compressed:    twoMembers,
+			Expected:      append(append([]byte{}, original...), "second member"...),
+			ExpectedError: assert.NoError,
+		},
+		"gzip single stream": {
+			Decoders:      gzipDecoders,
+			Options:       []DecompressorOption{WithGZIPDecoderOptions(GZIPDecoderOptions{SingleStream: true})},
+			Compressed:    twoMembers,
+			Expected:      original,
+			ExpectedError: assert.NoError,
+		},
+		"flate dictionary": {
+			Decoders:      flateDecoders,
+			Options:       []DecompressorOption{WithFlateDecoderOptions(FlateDecoderOptions{Dictionary: flateDict, BufferSize: 8 << 10})},
+			Compressed:    flateDictBytes(t, flateDict, original),
+			Expected:      original,
+			ExpectedError: assert.NoError,
+		},
+		"flate missing dictionary": {
+			Decoders:      flateDecoders,
+			Compressed:    flateDictBytes(t, flateDict, original),
+			ExpectedError: assert.Error,
+		},
+		"br buffer size": {
+			Decoders:      brDecoders,
+			Options:       []DecompressorOption{WithBRDecoderOptions(BRDecoderOptions{BufferSize: 32 << 10})},
+			Compressed:    compressBytes(t, NewBRBodyCompressorPool(defaultBRLevel), original),
+			Expected:      original,
+			ExpectedError: assert.NoError,
+		},
+		"zstd concurrency and memory": {
+			Decoders: map[string]decoderFactory{"zstd": zstdDecoders["zstd"]},
+			Options: []DecompressorOption{WithZSTDDecoderOptions(ZSTDDecoderOptions{
+				Concurrency:   -1,
+				DisableLowMem: true,
+				MaxMemory:     64 << 20,
+			})},
+			Compressed:    zstdBytes(t, large, zstd.WithEncoderConcurrency(1)),
+			Expected:      large,
+			ExpectedError: assert.NoError,
+		},
+		"zstd pool memory": {
+			Decoders: map[string]decoderFactory{"zstd pool": zstdDecoders["zstd pool"]},
+			Options: []DecompressorOption{WithZSTDDecoderOptions(ZSTDDecoderOptions{
+				Concurrency:   1,
+				DisableLowMem: true,
+				MaxMemory:     64 << 20,
+			})},
+			Compressed:    zstdBytes(t, large, zstd.WithEncoderConcurrency(1)),
+			Expected:      large,
+			ExpectedError: assert.NoError,
+		},
+		"zstd window exceeds max window": {
+			Decoders:      zstdDecoders,
+			Options:       []DecompressorOption{WithZSTDDecoderOptions(ZSTDDecoderOptions{MaxWindow: 64 << 10})},
+			Compressed:    zstdBytes(t, large, zstd.WithWindowSize(1<<20), zstd.WithEncoderConcurrency(1)),
+			ExpectedError: assert.Error,
+		},
+		"zstd dictionaries": {
+			Decoders:      zstdDecoders,
+			Options:       []DecompressorOption{WithZSTDDecoderOptions(ZSTDDecoderOptions{Dictionaries: [][]byte{zstdDict}})},
+			Compressed:    zstdBytes(t, original, zstd.WithEncoderDict(zstdDict), zstd.WithEncoderConcurrency(1)),
+			Expected:      original,
+			ExpectedError: assert.NoError,
+		},
+		"zstd missing dictionary": {
+			Decoders:      zstdDecoders,
+			Compressed:    zstdBytes(t, original, zstd.WithEncoderDict(zstdDict), zstd.WithEncoderConcurrency(1)),
+			ExpectedError: assert.Error,
+		},
+	}
+
+	for name, tc := range tests {
+		for decoderName, newDecoder := range tc.Decoders {
+			t.Run(name+" "+decoderName, func(t *testing.T) {
+				decoder := newDecoder(tc.Options...)
+
+				// twice, so that the pooled decoders and buffers are reused.
+				for range 2 {
+					wb := decoder.WrapBody(io.NopCloser(bytes.NewReader(tc.Compressed)))
+					got, err := io.ReadAll(wb)
+					_ = wb.Close()
+
+					tc.ExpectedError(t, err)
+					if err == nil {
+						assert.Equal(t, string(tc.Expected), string(got))
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestZSTDBodyDecompressorPoolConcurrency(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<17)
+	compressed := zstdBytes(t, large, zstd.WithEncoderConcurrency(1))
+
+	before := runtime.NumGoroutine()
+
+	// the partially read bodies would leave the goroutines of a concurrent decoder running.
+	pool := NewZSTDBodyDecompressorPool(WithZSTDDecoderOptions(ZSTDDecoderOptions{Concurrency: 1}))
+	for range 5 {
+		wb := pool.WrapBody(io.NopCloser(bytes.NewReader(compressed)))
+		_, err := io.ReadFull(wb, make([]byte, 10))
+		require.NoError(t, err)
+		require.NoError(t, wb.Close())
+	}
+
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+
+	for _, concurrency := range []int{4, -1} {
+		pool := NewZSTDBodyDecompressorPool(WithZSTDDecoderOptions(ZSTDDecoderOptions{Concurrency: concurrency}))
+		wb := pool.WrapBody(io.NopCloser(bytes.NewReader(compressed)))
+		_, err := io.ReadAll(wb)
+		require.ErrorIs(t, err, ErrZSTDPoolConcurrency)
+		_ = wb.Close()
+	}
+}
@@ -0,0 +1,158 @@
+package compress
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Compression Dictionary Transport (RFC 9842) content encoding and headers.
+const (
+	contentEncodingDCZ = "dcz"
+
+	AvailableDictionary = "Available-Dictionary"
+	DictionaryID        = "Dictionary-ID"
+)
+
+// dczMagic is the fixed prefix of a dictionary-compressed zstd stream, followed by the SHA-256 of the dictionary.
+var dczMagic = []byte{0x5e, 0x2a, 0x4d, 0x18, 0x20, 0x00, 0x00, 0x00}
+
+// DictionaryHash is the SHA-256 hash of a dictionary's content.
+type DictionaryHash [sha256.Size]byte
+
+// StructuredField returns the hash as a structured field byte sequence, as used by the Available-Dictionary header.
+func (h DictionaryHash) StructuredField() string {
+	return ":" + base64.StdEncoding.EncodeToString(h[:]) + ":"
+}
+
+// Dictionary is a pre-loaded shared compression dictionary.
+type Dictionary struct {
+	Content []byte
+	// ID is an optional server provided id, sent back with the Dictionary-ID request header.
+	ID string
+	// Match reports whether the dictionary is available for the request. Nil matches every request.
+	Match func(req *http.Request) bool
+
+	hash DictionaryHash
+}
+
+// Hash returns the SHA-256 hash of the dictionary's content.
+func (d *Dictionary) Hash() DictionaryHash {
+	return d.hash
+}
+
+// DictionaryStore keeps the pre-loaded dictionaries keyed by their hash. It is safe for concurrent use.
+type DictionaryStore struct {
+	mu     sync.RWMutex
+	byHash map[DictionaryHash]*Dictionary
+	order  []*Dictionary
+}
+
+func NewDictionaryStore(dicts ...Dictionary) *DictionaryStore {
+	s := &DictionaryStore{byHash: map[DictionaryHash]*Dictionary{}}
+	for _, d := range dicts {
+		s.Add(d)
+	}
+
+	return s
+}
+
+// Add stores the dictionary (replacing any other with the same content) and returns its hash.
+func (s *DictionaryStore) Add(d Dictionary) DictionaryHash {
+	d.hash = sha256.Sum256(d.Content)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.byHash[d.hash]; exists {
+		for i, o := range s.order {
+			if o.hash == d.hash {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+
+	s.byHash[d.hash] = &d
+	s.order = append(s.order, &d)
+
+	return d.hash
+}
+
+// Get returns the dictionary with the given hash.
+func (s *DictionaryStore) Get(hash DictionaryHash) (*Dictionary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, exists := s.byHash[hash]
+	return d, exists
+}
+
+// Select returns the first (in order of addition) dictionary that matches the request.
+func (s *DictionaryStore) Select(req *http.Request) (*Dictionary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, d := range s.order {
+		if d.Match == nil || d.Match(req) {
+			return d, true
+		}
+	}
+
+	return nil, false
+}
+
+// DictionaryBodyDecoder decodes dictionary compressed bodies. The dictionary is looked up by the hash found in the stream
+// header, when expected is not nil the header hash has to be equal to it.
+type DictionaryBodyDecoder interface {
+	WrapDictionaryBody(body io.ReadCloser, expected *DictionaryHash) io.ReadCloser
+}
+
+// WithDictionaries sets the pre-loaded dictionaries that are used to decode the dictionary compressed bodies.
+func WithDictionaries(store *DictionaryStore) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.dictionaries = store
+	}
+}
+
+var (
+	ErrDictionaryNotFound      = errors.New("compression dictionary not found")
+	ErrDictionaryHashMismatch  = errors.New("compression dictionary hash mismatch")
+	ErrDictionaryStreamInvalid = errors.New("invalid dictionary compressed stream header")
+)
+
+// readDictionaryHeader reads the magic and the dictionary hash that prefix a dictionary compressed stream and returns the matching dictionary.
+// When expected is not nil the hash has to be equal to it.
+func readDictionaryHeader(r io.Reader, magic []byte, store *DictionaryStore, expected *DictionaryHash) (*Dictionary, error) {
+	header := make([]byte, len(magic)+sha256.Size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Join(ErrDictionaryStreamInvalid, err)
+	}
+
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return nil, ErrDictionaryStreamInvalid
+	}
+
+	var hash DictionaryHash
+	copy(hash[:], header[len(magic):])
+
+	if expected != nil && *expected != hash {
+		return nil, ErrDictionaryHashMismatch
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("%w: %x", ErrDictionaryNotFound, hash)
+	}
+
+	d, exists := store.Get(hash)
+	if !exists {
+		return nil, fmt.Errorf("%w: %x", ErrDictionaryNotFound, hash)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,233 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dczBytes(t *testing.T, dict, b []byte) []byte {
+	t.Helper()
+
+	hash := sha256.Sum256(dict)
+	buf := &bytes.Buffer{}
+	buf.Write(dczMagic)
+	buf.Write(hash[:])
+
+	enc, err := zstd.NewWriter(buf, zstd.WithEncoderDictRaw(0, dict), zstd.WithEncoderConcurrency(1))
+	require.NoError(t, err)
+	_, err = enc.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+
+	return buf.Bytes()
+}
+
+func TestDictionaryStore(t *testing.T) {
+	api := Dictionary{
+		Content: []byte("api dictionary"),
+		ID:      "api",
+		Match:   func(req *http.Request) bool { return strings.HasPrefix(req.URL.Path, "/api/") },
+	}
+	fallback := Dictionary{Content: []byte("fallback dictionary")}
+
+	s := NewDictionaryStore(api, fallback)
+
+	d, exists := s.Get(sha256.Sum256(api.Content))
+	require.True(t, exists)
+	assert.Equal(t, "api", d.ID)
+
+	_, exists = s.Get(sha256.Sum256([]byte("unknown")))
+	assert.False(t, exists)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test/api/users", nil)
+	require.NoError(t, err)
+	d, exists = s.Select(req)
+	require.True(t, exists)
+	assert.Equal(t, api.Content, d.Content)
+
+	req, err = http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test/static/app.js", nil)
+	require.NoError(t, err)
+	d, exists = s.Select(req)
+	require.True(t, exists)
+	assert.Equal(t, fallback.Content, d.Content)
+
+	// re-adding moves the dictionary to the end of the selection order.
+	api.Match = nil
+	s.Add(api)
+	d, exists = s.Select(req)
+	require.True(t, exists)
+	assert.Equal(t, fallback.Content, d.Content)
+
+	hash := d.Hash()
+	assert.Equal(t, ":"+base64.StdEncoding.EncodeToString(hash[:])+":", hash.StructuredField())
+}
+
+func TestZSTDDictionaryBodyDecoder(t *testing.T) {
+	dict := []byte(strings.Repeat(`{"id": 0, "name": "", "email": "", "active": true}`, 20))
+	original := []byte(strings.Repeat(`{"id": 42, "name": "john", "email": "john@domain.test", "active": true}`, 50))
+	store := NewDictionaryStore(Dictionary{Content: dict})
+
+	dcz := dczBytes(t, dict, original)
+	unknown := dczBytes(t, []byte("unknown dictionary"), original)
+	hash := DictionaryHash(sha256.Sum256(dict))
+	otherHash := DictionaryHash(sha256.Sum256([]byte("other")))
+
+	decoders := map[string]DictionaryBodyDecoder{
+		"plain": NewZSTDBodyDecompressor(WithDictionaries(store)),
+		"pool":  NewZSTDBodyDecompressorPool(WithDictionaries(store)),
+	}
+
+	tests := map[string]struct {
+		Body          []byte
+		Expected      *DictionaryHash
+		ExpectedError error
+	}{
+		"decode":               {Body: dcz},
+		"decode expected hash": {Body: dcz, Expected: &hash},
+		"hash mismatch":        {Body: dcz, Expected: &otherHash, ExpectedError: ErrDictionaryHashMismatch},
+		"unknown dictionary":   {Body: unknown, ExpectedError: ErrDictionaryNotFound},
+		"invalid magic":        {Body: compressBytes(t, NewZSTDBodyCompressorPool(defaultZSTDLevel), original), ExpectedError: ErrDictionaryStreamInvalid},
+		"truncated header":     {Body: dcz[:20], ExpectedError: ErrDictionaryStreamInvalid},
+	}
+
+	for decoderName, decoder := range decoders {
+		for name, tc := range tests {
+			t.Run(decoderName+" "+name, func(t *testing.T) {
+				// twice, so that the pool reuses the decoder.
+				for range 2 {
+					rc := decoder.WrapDictionaryBody(io.NopCloser(bytes.NewReader(tc.Body)), tc.Expected)
+					got, err := io.ReadAll(rc)
+					if tc.ExpectedError != nil {
+						require.ErrorIs(t, err, tc.ExpectedError)
+						_ = rc.Close()
+						continue
+					}
+
+					require.NoError(t, err)
+					require.NoError(t, rc.Close())
+					assert.Equal(t, string(original), string(got))
+				}
+			})
+		}
+	}
+}
+
+func TestRoundTripperDictionary(t *testing.T) {
+	dict := []byte(strings.Repeat(`{"id": 0, "name": "", "email": ""}`, 20))
+	otherDict := []byte("another dictionary")
+	original := []byte(strings.Repeat(`{"id": 42, "name": "john", "email": "john@domain.test"}`, 50))
+
+	store := NewDictionaryStore(Dictionary{
+		Content: dict,
+		ID:      "users-v1",
+		Match:   func(req *http.Request) bool { return strings.HasPrefix(req.URL.Path, "/users") },
+	}, Dictionary{
+		Content: otherDict,
+		Match:   func(req *http.Request) bool { return strings.HasPrefix(req.URL.Path, "/other") },
+	})
+
+	tests := map[string]struct {
+		Path                        string
+		ContentEncoding             string
+		Body                        []byte
+		ExpectedAcceptEncoding      string
+		ExpectedAvailableDictionary string
+		ExpectedDictionaryID        string
+		ExpectedError               error
+	}{
+		"dcz": {
+			Path:                        "/users",
+			ContentEncoding:             "dcz",
+			Body:                        dczBytes(t, dict, original),
+			ExpectedAcceptEncoding:      "gzip, zstd, dcz",
+			ExpectedAvailableDictionary: DictionaryHash(sha256.Sum256(dict)).StructuredField(),
+			ExpectedDictionaryID:        `"users-v1"`,
+		},
+		"server responds with zstd": {
+			Path:                        "/users",
+			ContentEncoding:             "zstd",
+			Body:                        compressBytes(t, NewZSTDBodyCompressorPool(defaultZSTDLevel), original),
+			ExpectedAcceptEncoding:      "gzip, zstd, dcz",
+			ExpectedAvailableDictionary: DictionaryHash(sha256.Sum256(dict)).StructuredField(),
+			ExpectedDictionaryID:        `"users-v1"`,
+		},
+		"not the advertised dictionary": {
+			Path:                        "/other",
+			ContentEncoding:             "dcz",
+			Body:                        dczBytes(t, dict, original),
+			ExpectedAcceptEncoding:      "gzip, zstd, dcz",
+			ExpectedAvailableDictionary: DictionaryHash(sha256.Sum256(otherDict)).StructuredField(),
+			ExpectedError:               ErrDictionaryHashMismatch,
+		},
+		"no matching dictionary": {
+			Path:                   "/static",
+			ContentEncoding:        "gzip",
+			Body:                   compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), original),
+			ExpectedAcceptEncoding: "gzip, zstd",
+		},
+		"dcz without advertised dictionary": {
+			Path:                   "/static",
+			ContentEncoding:        "dcz",
+			Body:                   dczBytes(t, dict, original),
+			ExpectedAcceptEncoding: "gzip, zstd",
+			ExpectedError:          ErrUnsupportedContentEncoding,
+		},
+	}
+
+	for _, useReaderPool := range []bool{false, true} {
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				var sent *http.Request
+				next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					sent = req
+					resp := okResponse(req)
+					resp.Header.Set("Content-Encoding", tc.ContentEncoding)
+					resp.Body = io.NopCloser(bytes.NewReader(tc.Body))
+					return resp, nil
+				})
+
+				req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test"+tc.Path, nil)
+				require.NoError(t, err)
+
+				rt := NewRoundTripper(
+					next,
+					WithCompressionTypeGZIP(useReaderPool),
+					WithCompressionTypeZSTD(useReaderPool),
+					WithCompressionDictionaries(store, useReaderPool),
+				)
+
+				resp, err := rt.RoundTrip(req)
+				require.NotNil(t, sent)
+				assert.Equal(t, tc.ExpectedAcceptEncoding, sent.Header.Get(AcceptEncoding))
+				assert.Equal(t, tc.ExpectedAvailableDictionary, sent.Header.Get(AvailableDictionary))
+				assert.Equal(t, tc.ExpectedDictionaryID, sent.Header.Get(DictionaryID))
+
+				if err != nil {
+					require.ErrorIs(t, err, tc.ExpectedError)
+					return
+				}
+
+				got, err := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if tc.ExpectedError != nil {
+					require.ErrorIs(t, err, tc.ExpectedError)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, string(original), string(got))
+				assert.Empty(t, resp.Header.Get("Content-Encoding"))
+			})
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package compress
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Compressor is the low level stream encoder that every supported algorithm implements.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// EncodeWriter is the writer returned by a [BodyEncoder]. Close finalizes the encoded stream (footer / checksum)
+// but it does not close the underlying writer.
+type EncodeWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type BodyEncoder interface {
+	WrapWriter(w io.Writer) EncodeWriter
+}
+
+type compressorWriterWrapper[C Compressor] struct {
+	Writer          io.Writer
+	GetEncoderFn    func(w io.Writer) (C, error)
+	ReturnEncoderFn func(encoder C) error
+
+	stickyError error
+	encoder     C
+	onceInit    sync.Once
+	onceClose   sync.Once
+}
+
+func (c *compressorWriterWrapper[C]) initEncoder() {
+	c.onceInit.Do(func() {
+		var err error
+		c.encoder, err = c.GetEncoderFn(c.Writer)
+		if err != nil {
+			c.stickyError = err
+		}
+	})
+}
+
+func (c *compressorWriterWrapper[C]) Write(p []byte) (int, error) {
+	c.initEncoder()
+
+	if c.stickyError != nil {
+		return 0, c.stickyError
+	}
+
+	return c.encoder.Write(p)
+}
+
+func (c *compressorWriterWrapper[C]) Flush() error {
+	c.initEncoder()
+
+	if c.stickyError != nil {
+		return c.stickyError
+	}
+
+	return c.encoder.Flush()
+}
+
+func (c *compressorWriterWrapper[C]) Close() error {
+	c.initEncoder()
+
+	c.onceClose.Do(func() {
+		if c.stickyError != nil {
+			return
+		}
+
+		cErr := c.encoder.Close()
+		rErr := c.ReturnEncoderFn(c.encoder)
+
+		c.stickyError = errors.Join(cErr, rErr)
+		if c.stickyError == nil {
+			c.stickyError = ErrEncoderClosed
+		}
+	})
+
+	if errors.Is(c.stickyError, ErrEncoderClosed) {
+		return nil
+	}
+
+	return c.stickyError
+}
+
+var ErrEncoderClosed = errors.New("the encoder is already closed")
@@ -5,52 +5,85 @@ import (
 	"errors"
 	"io"
 	"sync"
+
+	kflate "github.com/klauspost/compress/flate"
 )
 
 type FlateBodyDecompressorPool struct {
 	readerPool sync.Pool
+	buffers    *readBufferPool
+	config     decompressorConfig
 }
 
-func NewFlateBodyDecompressorPool() *FlateBodyDecompressorPool {
+func NewFlateBodyDecompressorPool(opts ...DecompressorOption) *FlateBodyDecompressorPool {
+	config := newDecompressorConfig(opts)
 	return &FlateBodyDecompressorPool{
-		readerPool: sync.Pool{New: func() any { return &flateWrapper{r: flate.NewReader(nil)} }},
+		config:  config,
+		buffers: newReadBufferPool(config.flate.BufferSize),
+		readerPool: sync.Pool{New: func() any {
+			return &flateWrapper{r: flate.NewReaderDict(nil, config.flate.Dictionary), dict: config.flate.Dictionary}
+		}},
 	}
 }
 
 func (d *FlateBodyDecompressorPool) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *FlateBodyDecompressorPool) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	var buffered io.Reader
+
 	return &decompressorBodyWrapper[*flateWrapper]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*flateWrapper, error) {
+			buffered = d.buffers.get(compressedBody)
 			gz, _ := d.readerPool.Get().(*flateWrapper)
-			return gz, gz.Reset(compressedBody)
+			return gz, gz.Reset(buffered)
 		},
 		ReturnDecoderFn: func(decoder *flateWrapper) error {
 			d.readerPool.Put(decoder)
+			d.buffers.put(buffered)
 			return nil
 		},
 	}
 }
 
-type FlateBodyDecompressor struct{}
+type FlateBodyDecompressor struct {
+	buffers *readBufferPool
+	config  decompressorConfig
+}
 
-func NewFlateBodyDecompressor() *FlateBodyDecompressor {
-	return &FlateBodyDecompressor{}
+func NewFlateBodyDecompressor(opts ...DecompressorOption) *FlateBodyDecompressor {
+	config := newDecompressorConfig(opts)
+	return &FlateBodyDecompressor{
+		config:  config,
+		buffers: newReadBufferPool(config.flate.BufferSize),
+	}
 }
 
 func (d *FlateBodyDecompressor) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *FlateBodyDecompressor) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	var buffered io.Reader
+
 	return &decompressorBodyWrapper[*flateWrapper]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*flateWrapper, error) {
-			return &flateWrapper{r: flate.NewReader(compressedBody)}, nil
+			buffered = d.buffers.get(compressedBody)
+			return &flateWrapper{r: flate.NewReaderDict(buffered, d.config.flate.Dictionary)}, nil
 		},
 		ReturnDecoderFn: func(_ *flateWrapper) error {
+			d.buffers.put(buffered)
 			return nil
 		},
 	}
 }
 
 type flateWrapper struct {
-	r io.ReadCloser
+	r    io.ReadCloser
+	dict []byte
 }
 
 func (f *flateWrapper) Read(p []byte) (n int, err error) {
@@ -65,10 +98,48 @@ func (f *flateWrapper) Reset(compressedBody io.Reader) error {
 	if rs, is := f.r.(interface {
 		Reset(r io.Reader, dict []byte) error
 	}); is {
-		return rs.Reset(compressedBody, nil)
+		return rs.Reset(compressedBody, f.dict)
 	}
 
 	return ErrDeflateMissingReset
 }
 
 var ErrDeflateMissingReset = errors.New("the internal flate reader does not implement reset")
+
+type flateEncoderWrapper struct {
+	encoder   *kflate.Writer
+	initError error
+}
+
+type FlateBodyCompressorPool struct {
+	writerPool sync.Pool
+}
+
+// NewFlateBodyCompressorPool returns a pooled (raw) deflate [BodyEncoder] that uses the given compression level (e.g. [flate.DefaultCompression]).
+func NewFlateBodyCompressorPool(level int) *FlateBodyCompressorPool {
+	return &FlateBodyCompressorPool{
+		writerPool: sync.Pool{New: func() any {
+			w := &flateEncoderWrapper{}
+			w.encoder, w.initError = kflate.NewWriter(nil, level)
+			return w
+		}},
+	}
+}
+
+func (c *FlateBodyCompressorPool) WrapWriter(w io.Writer) EncodeWriter {
+	return &compressorWriterWrapper[*kflate.Writer]{
+		Writer: w,
+		GetEncoderFn: func(w io.Writer) (*kflate.Writer, error) {
+			ew, _ := c.writerPool.Get().(*flateEncoderWrapper)
+			if ew.initError != nil {
+				return nil, ew.initError
+			}
+			ew.encoder.Reset(w)
+			return ew.encoder, nil
+		},
+		ReturnEncoderFn: func(encoder *kflate.Writer) error {
+			c.writerPool.Put(&flateEncoderWrapper{encoder: encoder, initError: nil})
+			return nil
+		},
+	}
+}
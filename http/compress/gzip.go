@@ -9,41 +9,116 @@ import (
 
 type GZIPBodyDecompressorPool struct {
 	readerPool sync.Pool
+	buffers    *readBufferPool
+	config     decompressorConfig
 }
 
-func NewGZIPBodyDecompressorPool() *GZIPBodyDecompressorPool {
+func NewGZIPBodyDecompressorPool(opts ...DecompressorOption) *GZIPBodyDecompressorPool {
+	config := newDecompressorConfig(opts)
 	return &GZIPBodyDecompressorPool{
+		config:     config,
+		buffers:    newReadBufferPool(config.gzip.BufferSize),
 		readerPool: sync.Pool{New: func() any { return &gzip.Reader{} }},
 	}
 }
 
 func (d *GZIPBodyDecompressorPool) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *GZIPBodyDecompressorPool) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	var buffered io.Reader
+
 	return &decompressorBodyWrapper[*gzip.Reader]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*gzip.Reader, error) {
+			buffered = d.buffers.get(compressedBody)
 			gz, _ := d.readerPool.Get().(*gzip.Reader)
-			return gz, gz.Reset(compressedBody)
+			if err := gz.Reset(buffered); err != nil {
+				return nil, err
+			}
+			gz.Multistream(!d.config.gzip.SingleStream)
+			return gz, nil
 		},
 		ReturnDecoderFn: func(decoder *gzip.Reader) error {
 			d.readerPool.Put(decoder)
+			d.buffers.put(buffered)
 			return nil
 		},
 	}
 }
 
-type GZIPBodyDecompressor struct{}
+type GZIPBodyDecompressor struct {
+	buffers *readBufferPool
+	config  decompressorConfig
+}
 
-func NewGZIPBodyDecompressor() *GZIPBodyDecompressor {
-	return &GZIPBodyDecompressor{}
+func NewGZIPBodyDecompressor(opts ...DecompressorOption) *GZIPBodyDecompressor {
+	config := newDecompressorConfig(opts)
+	return &GZIPBodyDecompressor{
+		config:  config,
+		buffers: newReadBufferPool(config.gzip.BufferSize),
+	}
 }
 
 func (d *GZIPBodyDecompressor) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *GZIPBodyDecompressor) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	var buffered io.Reader
+
 	return &decompressorBodyWrapper[*gzip.Reader]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*gzip.Reader, error) {
-			return gzip.NewReader(compressedBody)
+			buffered = d.buffers.get(compressedBody)
+			gz, err := gzip.NewReader(buffered)
+			if err != nil {
+				return nil, err
+			}
+			gz.Multistream(!d.config.gzip.SingleStream)
+			return gz, nil
 		},
 		ReturnDecoderFn: func(_ *gzip.Reader) error {
+			d.buffers.put(buffered)
+			return nil
+		},
+	}
+}
+
+type gzipEncoderWrapper struct {
+	encoder   *gzip.Writer
+	initError error
+}
+
+type GZIPBodyCompressorPool struct {
+	writerPool sync.Pool
+}
+
+// NewGZIPBodyCompressorPool returns a pooled gzip [BodyEncoder] that uses the given compression level (e.g. [gzip.DefaultCompression]).
+func NewGZIPBodyCompressorPool(level int) *GZIPBodyCompressorPool {
+	return &GZIPBodyCompressorPool{
+		writerPool: sync.Pool{New: func() any {
+			w := &gzipEncoderWrapper{}
+			w.encoder, w.initError = gzip.NewWriterLevel(nil, level)
+			return w
+		}},
+	}
+}
+
+func (c *GZIPBodyCompressorPool) WrapWriter(w io.Writer) EncodeWriter {
+	return &compressorWriterWrapper[*gzip.Writer]{
+		Writer: w,
+		GetEncoderFn: func(w io.Writer) (*gzip.Writer, error) {
+			ew, _ := c.writerPool.Get().(*gzipEncoderWrapper)
+			if ew.initError != nil {
+				return nil, ew.initError
+			}
+			ew.encoder.Reset(w)
+			return ew.encoder, nil
+		},
+		ReturnEncoderFn: func(encoder *gzip.Writer) error {
+			c.writerPool.Put(&gzipEncoderWrapper{encoder: encoder, initError: nil})
 			return nil
 		},
 	}
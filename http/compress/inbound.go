@@ -1,3 +1,216 @@
 package compress
 
-// CompressMiddleware() func(next http.Handler) http.Handler
+import (
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+)
+
+const (
+	defaultGZIPLevel    = gzip.DefaultCompression
+	defaultDeflateLevel = flate.DefaultCompression
+	defaultBRLevel      = 4 // lower than brotli.DefaultCompression (6), which is too slow for on the fly compression.
+	defaultZSTDLevel    = 3
+
+	// DefaultMinSize is the default minimum size (in bytes) of a response body in order to be compressed.
+	DefaultMinSize = 1024
+)
+
+// CompressMiddleware returns an http middleware that compresses the response bodies according to the request's
+// Accept-Encoding header. By default gzip, deflate, br and zstd are supported, when no WithEncoder* option is provided.
+// A request that forbids the unencoded body (identity;q=0) and accepts none of the encodings gets a 406.
+func CompressMiddleware(opts ...MiddlewareOption) func(next http.Handler) http.Handler {
+	m := NewMiddleware(opts...)
+	return m.Handler
+}
+
+func NewMiddleware(opts ...MiddlewareOption) *Middleware {
+	m := &Middleware{
+		omitCondition:         nil,
+		compressibleType:      DefaultCompressibleContentType,
+		minSize:               DefaultMinSize,
+		contentEncoders:       map[string]BodyEncoder{},
+		contentEncodingsOrder: nil,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	if len(m.contentEncoders) == 0 {
+		m.defaultInit()
+	}
+
+	return m
+}
+
+type MiddlewareOption func(m *Middleware)
+
+// WithEncoder registers a custom encoder for the given content encoding.
+// The order of registration is used as the server's preference when the client accepts more than one encodings with the same weight.
+func WithEncoder(contentEncoding string, encoder BodyEncoder) MiddlewareOption {
+	return func(m *Middleware) {
+		m.addEncoder(contentEncoding, encoder)
+	}
+}
+
+func WithEncoderGZIP(level int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.addEncoder(contentEncodingGZIP, NewGZIPBodyCompressorPool(level))
+	}
+}
+
+func WithEncoderDeflate(level int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.addEncoder(contentEncodingDeflate, NewFlateBodyCompressorPool(level))
+	}
+}
+
+func WithEncoderBR(level int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.addEncoder(contentEncodingBR, NewBRBodyCompressorPool(level))
+	}
+}
+
+func WithEncoderZSTD(level int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.addEncoder(contentEncodingZSTD, NewZSTDBodyCompressorPool(level))
+	}
+}
+
+// WithMinSize sets the minimum response body size (in bytes) that will be compressed. Smaller responses are sent as is.
+func WithMinSize(minSize int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.minSize = max(minSize, 0)
+	}
+}
+
+// WithCompressibleContentType sets the function that decides, based on the response Content-Type, if a response should be compressed.
+func WithCompressibleContentType(fn func(contentType string) bool) MiddlewareOption {
+	return func(m *Middleware) {
+		m.compressibleType = fn
+	}
+}
+
+// WithMiddlewareOmitCondition sets a request based condition that, when true, disables the response compression.
+func WithMiddlewareOmitCondition(ec OmitCondition) MiddlewareOption {
+	return func(m *Middleware) {
+		m.omitCondition = ec
+	}
+}
+
+type Middleware struct {
+	omitCondition         OmitCondition
+	compressibleType      func(contentType string) bool
+	minSize               int
+	contentEncoders       map[string]BodyEncoder
+	contentEncodingsOrder []string
+}
+
+func (m *Middleware) addEncoder(contentEncoding string, encoder BodyEncoder) {
+	contentEncoding = strings.ToLower(contentEncoding)
+	_, exists := m.contentEncoders[contentEncoding]
+	m.contentEncoders[contentEncoding] = encoder
+	if !exists {
+		m.contentEncodingsOrder = append(m.contentEncodingsOrder, contentEncoding)
+	}
+}
+
+func (m *Middleware) defaultInit() {
+	WithEncoderZSTD(defaultZSTDLevel)(m)
+	WithEncoderBR(defaultBRLevel)(m)
+	WithEncoderGZIP(defaultGZIPLevel)(m)
+	WithEncoderDeflate(defaultDeflateLevel)(m)
+}
+
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), AcceptEncoding)
+
+		if m.omit(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		prefs := ParseAcceptEncoding(r.Header.Values(AcceptEncoding)...)
+		contentEncoding := prefs.Negotiate(m.contentEncodingsOrder)
+		encoder, exists := m.contentEncoders[contentEncoding]
+		if !exists {
+			// none of the encodings is acceptable and neither is the unencoded body (RFC 9110 section 12.5.3).
+			if !prefs.identityAcceptable() {
+				http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{
+			w:                w,
+			contentEncoding:  contentEncoding,
+			encoder:          encoder,
+			minSize:          m.minSize,
+			compressibleType: m.compressibleType,
+		}
+
+		defer func() {
+			_ = cw.Close()
+		}()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+func (m *Middleware) omit(r *http.Request) bool {
+	return r.Method == http.MethodHead ||
+		len(r.Header.Values(AcceptEncoding)) == 0 ||
+		(m.omitCondition != nil && m.omitCondition(r))
+}
+
+// DefaultCompressibleContentType excludes the content types that are (most probably) already compressed.
+func DefaultCompressibleContentType(contentType string) bool {
+	ct, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	ct = strings.TrimSpace(ct)
+
+	switch {
+	case ct == "image/svg+xml":
+		return true
+	case strings.HasPrefix(ct, "image/"),
+		strings.HasPrefix(ct, "video/"),
+		strings.HasPrefix(ct, "audio/"),
+		strings.HasPrefix(ct, "font/woff"):
+		return false
+	}
+
+	switch ct {
+	case "application/zip",
+		"application/gzip",
+		"application/x-gzip",
+		"application/zstd",
+		"application/x-brotli",
+		"application/x-bzip2",
+		"application/x-xz",
+		"application/x-7z-compressed",
+		"application/x-rar-compressed",
+		"application/pdf":
+		return false
+	}
+
+	return true
+}
+
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for existing := range strings.SplitSeq(v, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, value) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", value)
+}
@@ -0,0 +1,110 @@
+package compress
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxDecompressedSize is the default maximum size (in bytes) of a decompressed request body.
+const DefaultMaxDecompressedSize = 32 << 20
+
+// DecompressMiddleware returns an http middleware that transparently decompresses the request bodies according to the
+// request's Content-Encoding header. By default gzip, deflate, br and zstd are supported, when no WithRequestDecoder option is provided.
+// Requests with unsupported encoding are rejected with 415 (Unsupported Media Type).
+func DecompressMiddleware(opts ...RequestDecompressorOption) func(next http.Handler) http.Handler {
+	m := NewRequestDecompressor(opts...)
+	return m.Handler
+}
+
+func NewRequestDecompressor(opts ...RequestDecompressorOption) *RequestDecompressor {
+	m := &RequestDecompressor{
+		contentDecoders:     map[string]BodyDecoder{},
+		maxDecompressedSize: DefaultMaxDecompressedSize,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	if len(m.contentDecoders) == 0 {
+		m.defaultInit()
+	}
+
+	return m
+}
+
+type RequestDecompressorOption func(m *RequestDecompressor)
+
+// WithRequestDecoder registers a decoder for the given request content encoding.
+func WithRequestDecoder(contentEncoding string, decoder BodyDecoder) RequestDecompressorOption {
+	return func(m *RequestDecompressor) {
+		m.addDecoder(contentEncoding, decoder)
+	}
+}
+
+// WithMaxDecompressedSize sets the maximum size (in bytes) of a decompressed request body.
+// Reading past the limit returns an [*http.MaxBytesError]. Zero or negative value disables the limit.
+func WithMaxDecompressedSize(maxSize int64) RequestDecompressorOption {
+	return func(m *RequestDecompressor) {
+		m.maxDecompressedSize = maxSize
+	}
+}
+
+type RequestDecompressor struct {
+	contentDecoders     map[string]BodyDecoder
+	contentEncodings    []string
+	maxDecompressedSize int64
+}
+
+func (m *RequestDecompressor) addDecoder(contentEncoding string, decoder BodyDecoder) {
+	contentEncoding = strings.ToLower(contentEncoding)
+	_, exists := m.contentDecoders[contentEncoding]
+	m.contentDecoders[contentEncoding] = decoder
+	if !exists {
+		m.contentEncodings = append(m.contentEncodings, contentEncoding)
+	}
+}
+
+func (m *RequestDecompressor) defaultInit() {
+	m.addDecoder(contentEncodingGZIP, NewGZIPBodyDecompressorPool())
+	m.addDecoder(contentEncodingZSTD, NewZSTDBodyDecompressorPool())
+	m.addDecoder(contentEncodingBR, NewBRBodyDecompressorPool())
+	m.addDecoder(contentEncodingDeflate, NewFlateBodyDecompressorPool())
+}
+
+func (m *RequestDecompressor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if contentEncoding == "identity" {
+			r.Header.Del("Content-Encoding")
+		}
+
+		if contentEncoding == "" || contentEncoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decoder, exists := m.contentDecoders[contentEncoding]
+		if !exists {
+			m.unsupported(w)
+			return
+		}
+
+		r.Body = decoder.WrapBody(r.Body)
+		if m.maxDecompressedSize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, m.maxDecompressedSize)
+		}
+
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unsupported responds with 415 and the list of the supported encodings, as described in RFC 7694.
+func (m *RequestDecompressor) unsupported(w http.ResponseWriter) {
+	w.Header().Set(AcceptEncoding, strings.Join(m.contentEncodings, ", "))
+	http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+}
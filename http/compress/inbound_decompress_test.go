@@ -0,0 +1,117 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompressMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		Options                []RequestDecompressorOption
+		ContentEncoding        string
+		Body                   []byte
+		ExpectedStatus         int
+		ExpectedBody           string
+		ExpectedAcceptEncoding string
+	}{
+		"gzip": {
+			ContentEncoding: "gzip",
+			Body:            file1GZIPCompressed,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedBody:    string(file1Original),
+		},
+		"zstd upper case": {
+			ContentEncoding: "ZSTD",
+			Body:            file1ZSTDCompressed,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedBody:    string(file1Original),
+		},
+		"br": {
+			ContentEncoding: "br",
+			Body:            file1BRCompressed,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedBody:    string(file1Original),
+		},
+		"no encoding": {
+			ContentEncoding: "",
+			Body:            file1Original,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedBody:    string(file1Original),
+		},
+		"identity": {
+			ContentEncoding: "identity",
+			Body:            file1Original,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedBody:    string(file1Original),
+		},
+		"unsupported": {
+			ContentEncoding:        "compress",
+			Body:                   file1Original,
+			ExpectedStatus:         http.StatusUnsupportedMediaType,
+			ExpectedBody:           "Unsupported Media Type\n",
+			ExpectedAcceptEncoding: "gzip, zstd, br, deflate",
+		},
+		"unsupported with custom decoders": {
+			Options:                []RequestDecompressorOption{WithRequestDecoder("gzip", NewGZIPBodyDecompressor())},
+			ContentEncoding:        "zstd",
+			Body:                   file1ZSTDCompressed,
+			ExpectedStatus:         http.StatusUnsupportedMediaType,
+			ExpectedBody:           "Unsupported Media Type\n",
+			ExpectedAcceptEncoding: "gzip",
+		},
+		"max decompressed size": {
+			Options:         []RequestDecompressorOption{WithMaxDecompressedSize(10)},
+			ContentEncoding: "gzip",
+			Body:            file1GZIPCompressed,
+			ExpectedStatus:  http.StatusRequestEntityTooLarge,
+			ExpectedBody:    "",
+		},
+		"max decompressed size disabled": {
+			Options:         []RequestDecompressorOption{WithMaxDecompressedSize(0)},
+			ContentEncoding: "gzip",
+			Body:            file1GZIPCompressed,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedBody:    string(file1Original),
+		},
+	}
+
+	// echo handler
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Content-Encoding"))
+
+		b, err := io.ReadAll(r.Body)
+		if mbErr := (*http.MaxBytesError)(nil); errors.As(err, &mbErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, r.Body.Close())
+
+		_, _ = w.Write(b)
+	})
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "/", bytes.NewReader(tc.Body))
+			require.NoError(t, err)
+			if tc.ContentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.ContentEncoding)
+			}
+
+			rr := httptest.NewRecorder()
+			DecompressMiddleware(tc.Options...)(handler).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.ExpectedStatus, rr.Code)
+			assert.Equal(t, tc.ExpectedBody, rr.Body.String())
+			assert.Equal(t, tc.ExpectedAcceptEncoding, rr.Header().Get(AcceptEncoding))
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	largeBody := strings.Repeat("compress me please. ", 200)
+	decoders := map[string]BodyDecoder{
+		"gzip":    NewGZIPBodyDecompressor(),
+		"deflate": NewFlateBodyDecompressor(),
+		"br":      NewBRBodyDecompressor(),
+		"zstd":    NewZSTDBodyDecompressor(),
+	}
+
+	writeLarge := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "4000")
+		w.Header().Set("ETag", `"abc"`)
+		_, _ = io.WriteString(w, largeBody)
+	}
+
+	tests := map[string]struct {
+		Options                 []MiddlewareOption
+		Method                  string
+		AcceptEncoding          string
+		Handler                 http.HandlerFunc
+		ExpectedStatus          int
+		ExpectedContentEncoding string
+		ExpectedBody            string
+		ExpectedHeaders         map[string]string
+	}{
+		"gzip": {
+			AcceptEncoding:          "gzip",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "gzip",
+			ExpectedBody:            largeBody,
+			ExpectedHeaders:         map[string]string{"ETag": `W/"abc"`, "Vary": "Accept-Encoding"},
+		},
+		"deflate": {
+			AcceptEncoding:          "deflate",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "deflate",
+			ExpectedBody:            largeBody,
+		},
+		"br": {
+			AcceptEncoding:          "br",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "br",
+			ExpectedBody:            largeBody,
+		},
+		"zstd preferred": {
+			AcceptEncoding:          "gzip, deflate, br, zstd",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "zstd",
+			ExpectedBody:            largeBody,
+		},
+		"q values": {
+			AcceptEncoding:          "zstd;q=0.1, br;q=0.9",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "br",
+			ExpectedBody:            largeBody,
+		},
+		"custom encoders only": {
+			Options:                 []MiddlewareOption{WithEncoderGZIP(1)},
+			AcceptEncoding:          "zstd, gzip;q=0.1",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "gzip",
+			ExpectedBody:            largeBody,
+		},
+		"no accept encoding": {
+			AcceptEncoding:          "",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            largeBody,
+			ExpectedHeaders:         map[string]string{"Content-Length": "4000", "ETag": `"abc"`, "Vary": "Accept-Encoding"},
+		},
+		"identity not acceptable": {
+			AcceptEncoding:          "compress, identity;q=0",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusNotAcceptable,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            "Not Acceptable\n",
+			ExpectedHeaders:         map[string]string{"Vary": "Accept-Encoding"},
+		},
+		"nothing acceptable": {
+			AcceptEncoding:          "gzip;q=0, *;q=0",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusNotAcceptable,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            "Not Acceptable\n",
+		},
+		"only identity acceptable": {
+			AcceptEncoding:          "*;q=0, identity",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            largeBody,
+		},
+		"tiny response": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, `{"ok":true}`)
+			},
+			ExpectedStatus:          http.StatusCreated,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            `{"ok":true}`,
+		},
+		"many small writes": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				for range 200 {
+					_, _ = io.WriteString(w, "compress me please. ")
+				}
+			},
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "gzip",
+			ExpectedBody:            largeBody,
+			ExpectedHeaders:         map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		},
+		"content length without content type": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Length", "4000")
+				_, _ = io.WriteString(w, largeBody)
+			},
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "gzip",
+			ExpectedBody:            largeBody,
+			ExpectedHeaders:         map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		},
+		"content length without content type not compressible": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				png := "\x89PNG\r\n\x1a\n" + largeBody[:3992]
+				w.Header().Set("Content-Length", "4000")
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, png)
+			},
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            "\x89PNG\r\n\x1a\n" + largeBody[:3992],
+			ExpectedHeaders:         map[string]string{"Content-Type": "image/png", "Content-Length": "4000"},
+		},
+		"already encoded": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Encoding", "custom")
+				_, _ = io.WriteString(w, largeBody)
+			},
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "custom",
+			ExpectedBody:            largeBody,
+		},
+		"not compressible content type": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = io.WriteString(w, largeBody)
+			},
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            largeBody,
+		},
+		"not modified": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			},
+			ExpectedStatus:          http.StatusNotModified,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            "",
+		},
+		"head": {
+			Method:                  http.MethodHead,
+			AcceptEncoding:          "gzip",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            "",
+		},
+		"omit condition": {
+			Options: []MiddlewareOption{WithMiddlewareOmitCondition(func(r *http.Request) bool {
+				return r.URL.Path == "/"
+			})},
+			AcceptEncoding:          "gzip",
+			Handler:                 writeLarge,
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "",
+			ExpectedBody:            largeBody,
+		},
+		"flush before min size": {
+			AcceptEncoding: "gzip",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = io.WriteString(w, "data: 1\n\n")
+				w.(http.Flusher).Flush()
+				_, _ = io.WriteString(w, "data: 2\n\n")
+			},
+			ExpectedStatus:          http.StatusOK,
+			ExpectedContentEncoding: "gzip",
+			ExpectedBody:            "data: 1\n\ndata: 2\n\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(CompressMiddleware(tc.Options...)(tc.Handler))
+			t.Cleanup(srv.Close)
+
+			method := tc.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req, err := http.NewRequestWithContext(context.Background(), method, srv.URL+"/", nil)
+			require.NoError(t, err)
+			if tc.AcceptEncoding != "" {
+				req.Header.Set(AcceptEncoding, tc.AcceptEncoding)
+			}
+
+			// disable the transparent gzip decompression of the transport.
+			client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			assert.Equal(t, tc.ExpectedContentEncoding, resp.Header.Get("Content-Encoding"))
+			for k, v := range tc.ExpectedHeaders {
+				assert.Equal(t, v, resp.Header.Get(k), "header %s", k)
+			}
+
+			var body io.ReadCloser = resp.Body
+			if d, exists := decoders[tc.ExpectedContentEncoding]; exists {
+				body = d.WrapBody(resp.Body)
+			}
+
+			got, err := io.ReadAll(body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.ExpectedBody, string(got))
+		})
+	}
+}
+
+func TestBodyCompressorRoundTrip(t *testing.T) {
+	tests := map[string]struct {
+		Encoder BodyEncoder
+		Decoder BodyDecoder
+	}{
+		"gzip":    {Encoder: NewGZIPBodyCompressorPool(defaultGZIPLevel), Decoder: NewGZIPBodyDecompressorPool()},
+		"deflate": {Encoder: NewFlateBodyCompressorPool(defaultDeflateLevel), Decoder: NewFlateBodyDecompressorPool()},
+		"br":      {Encoder: NewBRBodyCompressorPool(defaultBRLevel), Decoder: NewBRBodyDecompressorPool()},
+		"zstd":    {Encoder: NewZSTDBodyCompressorPool(defaultZSTDLevel), Decoder: NewZSTDBodyDecompressorPool()},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// run twice to exercise the pooled instances.
+			for range 2 {
+				compressed := &bytes.Buffer{}
+				w := tc.Encoder.WrapWriter(compressed)
+				_, err := w.Write(file1Original)
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				require.NoError(t, w.Close()) // idempotent
+
+				_, err = w.Write([]byte("after close"))
+				require.ErrorIs(t, err, ErrEncoderClosed)
+
+				rc := tc.Decoder.WrapBody(io.NopCloser(compressed))
+				got, err := io.ReadAll(rc)
+				require.NoError(t, err)
+				require.NoError(t, rc.Close())
+				assert.Equal(t, string(file1Original), string(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrDecompressionLimitExceeded is matched (errors.Is) by every [*DecompressionLimitError].
+var ErrDecompressionLimitExceeded = errors.New("decompression limit exceeded")
+
+// expansionRatioMinBytes is the amount of decompressed bytes after which the expansion ratio limit is checked,
+// so that small and highly compressible bodies are not rejected.
+const expansionRatioMinBytes = 1 << 20
+
+// DecompressionLimits protects against decompression bombs. Zero value means no limit.
+type DecompressionLimits struct {
+	// MaxBytes is the maximum number of decompressed bytes.
+	MaxBytes int64
+	// MaxRatio is the maximum ratio of decompressed to compressed bytes.
+	// It is checked once more than 1MiB has been decompressed.
+	MaxRatio float64
+}
+
+func (l DecompressionLimits) enabled() bool {
+	return l.MaxBytes > 0 || l.MaxRatio > 0
+}
+
+// check returns the number of the decompressed bytes (of the last read) that are within the limits and an error if any limit is exceeded.
+func (l DecompressionLimits) check(compressed, decompressed int64, n int) (int, error) {
+	if l.MaxBytes > 0 && decompressed > l.MaxBytes {
+		return max(n-int(decompressed-l.MaxBytes), 0), &DecompressionLimitError{
+			Limits:       l,
+			Compressed:   compressed,
+			Decompressed: decompressed,
+		}
+	}
+
+	if l.MaxRatio > 0 && decompressed > expansionRatioMinBytes && float64(decompressed) > l.MaxRatio*float64(max(compressed, 1)) {
+		return n, &DecompressionLimitError{
+			Limits:       l,
+			Compressed:   compressed,
+			Decompressed: decompressed,
+		}
+	}
+
+	return n, nil
+}
+
+// DecompressionLimitError is returned from Read when a [DecompressionLimits] limit is exceeded.
+type DecompressionLimitError struct {
+	Limits       DecompressionLimits
+	Compressed   int64
+	Decompressed int64
+}
+
+func (e *DecompressionLimitError) Error() string {
+	return fmt.Sprintf(
+		"%s: compressed %d bytes, decompressed %d bytes (max bytes %d, max ratio %g)",
+		ErrDecompressionLimitExceeded.Error(), e.Compressed, e.Decompressed, e.Limits.MaxBytes, e.Limits.MaxRatio,
+	)
+}
+
+func (e *DecompressionLimitError) Is(target error) bool {
+	return target == ErrDecompressionLimitExceeded
+}
+
+// DecompressorOption configures the New*BodyDecompressor* constructors.
+type DecompressorOption func(c *decompressorConfig)
+
+type decompressorConfig struct {
+	limits       DecompressionLimits
+	dictionaries *DictionaryStore
+	zstd         ZSTDDecoderOptions
+	gzip         GZIPDecoderOptions
+	flate        FlateDecoderOptions
+	br           BRDecoderOptions
+}
+
+func newDecompressorConfig(opts []DecompressorOption) decompressorConfig {
+	c := decompressorConfig{}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return c
+}
+
+// WithMaxDecompressedBytes sets the maximum number of decompressed bytes of a body.
+func WithMaxDecompressedBytes(maxBytes int64) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.limits.MaxBytes = maxBytes
+	}
+}
+
+// WithMaxExpansionRatio sets the maximum ratio of decompressed to compressed bytes of a body.
+func WithMaxExpansionRatio(maxRatio float64) DecompressorOption {
+	return func(c *decompressorConfig) {
+		c.limits.MaxRatio = maxRatio
+	}
+}
+
+// countingReadCloser counts the bytes read from the compressed body.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedBody applies the decompression limits on the body produced by any [BodyDecoder].
+type limitedBody struct {
+	decoded      io.ReadCloser
+	compressed   *countingReadCloser
+	limits       DecompressionLimits
+	decompressed int64
+	limitErr     error
+}
+
+func limitBody(limits DecompressionLimits, compressedBody io.ReadCloser, decoder BodyDecoder) io.ReadCloser {
+	if !limits.enabled() {
+		return decoder.WrapBody(compressedBody)
+	}
+
+	counter := &countingReadCloser{ReadCloser: compressedBody}
+
+	return &limitedBody{
+		decoded:    decoder.WrapBody(counter),
+		compressed: counter,
+		limits:     limits,
+	}
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.limitErr != nil {
+		return 0, l.limitErr
+	}
+
+	n, err := l.decoded.Read(p)
+	l.decompressed += int64(n)
+
+	n, limitErr := l.limits.check(l.compressed.n, l.decompressed, n)
+	if limitErr != nil {
+		l.limitErr = limitErr
+		return n, limitErr
+	}
+
+	return n, err
+}
+
+func (l *limitedBody) Close() error {
+	return l.decoded.Close()
+}
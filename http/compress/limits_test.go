@@ -0,0 +1,155 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compressBytes(t *testing.T, enc BodyEncoder, b []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := enc.WrapWriter(buf)
+	_, err := w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestDecompressionLimits(t *testing.T) {
+	bomb := make([]byte, 4<<20) // 4MiB of zeros
+
+	gzipBomb := compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), bomb)
+	zstdBomb := compressBytes(t, NewZSTDBodyCompressorPool(defaultZSTDLevel), bomb)
+	brBomb := compressBytes(t, NewBRBodyCompressorPool(defaultBRLevel), bomb)
+	flateBomb := compressBytes(t, NewFlateBodyCompressorPool(defaultDeflateLevel), bomb)
+
+	tests := map[string]struct {
+		BodyDecompressor BodyDecoder
+		Compressed       []byte
+		ExpectedSize     int
+		ExpectedError    assert.ErrorAssertionFunc
+	}{
+		"gzip max bytes": {
+			BodyDecompressor: NewGZIPBodyDecompressor(WithMaxDecompressedBytes(1000)),
+			Compressed:       gzipBomb,
+			ExpectedSize:     1000,
+			ExpectedError:    errorIsLimit,
+		},
+		"gzip pool max ratio": {
+			BodyDecompressor: NewGZIPBodyDecompressorPool(WithMaxExpansionRatio(100)),
+			Compressed:       gzipBomb,
+			ExpectedSize:     -1,
+			ExpectedError:    errorIsLimit,
+		},
+		"zstd max bytes": {
+			BodyDecompressor: NewZSTDBodyDecompressor(WithMaxDecompressedBytes(2 << 20)),
+			Compressed:       zstdBomb,
+			ExpectedSize:     2 << 20,
+			ExpectedError:    errorIsLimit,
+		},
+		"zstd pool max ratio": {
+			BodyDecompressor: NewZSTDBodyDecompressorPool(WithMaxExpansionRatio(100)),
+			Compressed:       zstdBomb,
+			ExpectedSize:     -1,
+			ExpectedError:    errorIsLimit,
+		},
+		"br max bytes": {
+			BodyDecompressor: NewBRBodyDecompressor(WithMaxDecompressedBytes(1)),
+			Compressed:       brBomb,
+			ExpectedSize:     1,
+			ExpectedError:    errorIsLimit,
+		},
+		"br pool max ratio": {
+			BodyDecompressor: NewBRBodyDecompressorPool(WithMaxExpansionRatio(100)),
+			Compressed:       brBomb,
+			ExpectedSize:     -1,
+			ExpectedError:    errorIsLimit,
+		},
+		"flate max bytes": {
+			BodyDecompressor: NewFlateBodyDecompressor(WithMaxDecompressedBytes(1000)),
+			Compressed:       flateBomb,
+			ExpectedSize:     1000,
+			ExpectedError:    errorIsLimit,
+		},
+		"flate pool max ratio": {
+			BodyDecompressor: NewFlateBodyDecompressorPool(WithMaxExpansionRatio(100)),
+			Compressed:       flateBomb,
+			ExpectedSize:     -1,
+			ExpectedError:    errorIsLimit,
+		},
+		"within limits": {
+			BodyDecompressor: NewGZIPBodyDecompressor(WithMaxDecompressedBytes(8<<20), WithMaxExpansionRatio(100000)),
+			Compressed:       gzipBomb,
+			ExpectedSize:     len(bomb),
+			ExpectedError:    assert.NoError,
+		},
+		"ratio ignored for small bodies": {
+			BodyDecompressor: NewGZIPBodyDecompressor(WithMaxExpansionRatio(1.1)),
+			Compressed:       file1GZIPCompressed,
+			ExpectedSize:     len(file1Original),
+			ExpectedError:    assert.NoError,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			wb := tc.BodyDecompressor.WrapBody(io.NopCloser(bytes.NewReader(tc.Compressed)))
+
+			got, err := io.ReadAll(wb)
+			tc.ExpectedError(t, err)
+			if tc.ExpectedSize >= 0 {
+				assert.Len(t, got, tc.ExpectedSize)
+			}
+
+			// sticky error
+			if err != nil {
+				_, err = wb.Read(make([]byte, 10))
+				tc.ExpectedError(t, err)
+			}
+
+			require.NoError(t, wb.Close())
+		})
+	}
+}
+
+func TestRoundTripperDecompressionLimits(t *testing.T) {
+	gzipBomb := compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), make([]byte, 4<<20))
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp := okResponse(req)
+		resp.Header.Set("Content-Encoding", "gzip")
+		resp.Body = io.NopCloser(bytes.NewReader(gzipBomb))
+		return resp, nil
+	})
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test/", nil)
+	require.NoError(t, err)
+
+	resp, err := NewRoundTripper(next, WithDecompressionLimits(1<<20, 0)).RoundTrip(req)
+	require.NoError(t, err)
+
+	got, err := io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, ErrDecompressionLimitExceeded)
+	assert.Len(t, got, 1<<20)
+
+	var limitErr *DecompressionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, int64(1<<20), limitErr.Limits.MaxBytes)
+	assert.LessOrEqual(t, limitErr.Compressed, int64(len(gzipBomb)))
+	assert.Greater(t, limitErr.Decompressed, int64(1<<20))
+
+	require.NoError(t, resp.Body.Close())
+}
+
+func errorIsLimit(t assert.TestingT, err error, _ ...any) bool {
+	return assert.True(t, errors.Is(err, ErrDecompressionLimitExceeded), "expected decompression limit error, got %v", err)
+}
@@ -0,0 +1,191 @@
+package compress
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DecodeStats are the statistics of a decoded response body, reported to the [Observer] when the body is closed.
+type DecodeStats struct {
+	Request *http.Request
+	// ContentEncoding is the (lower cased, comma separated) content encoding of the response, e.g. "gzip" or "gzip, br".
+	ContentEncoding string
+	// Compressed is the number of bytes read from the response body.
+	Compressed int64
+	// Decompressed is the number of decoded bytes produced.
+	Decompressed int64
+	// Duration is the total time spent in the decoded body reads, including the time waiting for the network.
+	Duration time.Duration
+	// Err is the first read error (other than io.EOF), or the close error.
+	Err error
+}
+
+// Observer receives the statistics of every response decoded by the [RoundTripper]. It has to be safe for concurrent use.
+type Observer interface {
+	ObserveDecode(stats DecodeStats)
+}
+
+// ObserverFunc is an [Observer] signature alias.
+type ObserverFunc func(stats DecodeStats)
+
+// ObserveDecode implements the Observer interface.
+func (f ObserverFunc) ObserveDecode(stats DecodeStats) {
+	f(stats)
+}
+
+// WithObserver sets the observer that receives the statistics of every decoded response body, when the body is closed.
+// A response with an unsupported content encoding is reported right away, with the error.
+func WithObserver(o Observer) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.observer = o
+	}
+}
+
+// observedBody measures the decoded body and reports the stats on close.
+type observedBody struct {
+	decoded    io.ReadCloser
+	compressed *countingReadCloser
+	observer   Observer
+	stats      DecodeStats
+	once       sync.Once
+}
+
+func observeBody(o Observer, req *http.Request, contentEncoding string, compressedBody io.ReadCloser, decode func(io.ReadCloser) io.ReadCloser) io.ReadCloser {
+	counter := &countingReadCloser{ReadCloser: compressedBody}
+
+	return &observedBody{
+		decoded:    decode(counter),
+		compressed: counter,
+		observer:   o,
+		stats:      DecodeStats{Request: req, ContentEncoding: contentEncoding},
+	}
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := b.decoded.Read(p)
+	b.stats.Duration += time.Since(start)
+	b.stats.Decompressed += int64(n)
+
+	if err != nil && !errors.Is(err, io.EOF) && b.stats.Err == nil {
+		b.stats.Err = err
+	}
+
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.decoded.Close()
+
+	b.once.Do(func() {
+		b.stats.Compressed = b.compressed.n
+		if b.stats.Err == nil {
+			b.stats.Err = err
+		}
+		b.observer.ObserveDecode(b.stats)
+	})
+
+	return err
+}
+
+// EncodingStats are the aggregated statistics of a content encoding.
+type EncodingStats struct {
+	Responses    int64
+	Errors       int64
+	Compressed   int64
+	Decompressed int64
+	Duration     time.Duration
+}
+
+// Saved returns the bytes that were not transferred thanks to the compression.
+func (s EncodingStats) Saved() int64 {
+	return s.Decompressed - s.Compressed
+}
+
+// Ratio returns the ratio of decompressed to compressed bytes.
+func (s EncodingStats) Ratio() float64 {
+	if s.Compressed == 0 {
+		return 0
+	}
+
+	return float64(s.Decompressed) / float64(s.Compressed)
+}
+
+func (s EncodingStats) add(o EncodingStats) EncodingStats {
+	return EncodingStats{
+		Responses:    s.Responses + o.Responses,
+		Errors:       s.Errors + o.Errors,
+		Compressed:   s.Compressed + o.Compressed,
+		Decompressed: s.Decompressed + o.Decompressed,
+		Duration:     s.Duration + o.Duration,
+	}
+}
+
+// MemoryObserver is an [Observer] that aggregates the statistics in memory, per content encoding.
+type MemoryObserver struct {
+	mu         sync.Mutex
+	byEncoding map[string]EncodingStats
+}
+
+func NewMemoryObserver() *MemoryObserver {
+	return &MemoryObserver{byEncoding: map[string]EncodingStats{}}
+}
+
+// ObserveDecode implements the Observer interface.
+func (m *MemoryObserver) ObserveDecode(stats DecodeStats) {
+	s := EncodingStats{
+		Responses:    1,
+		Compressed:   stats.Compressed,
+		Decompressed: stats.Decompressed,
+		Duration:     stats.Duration,
+	}
+	if stats.Err != nil {
+		s.Errors = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.byEncoding[stats.ContentEncoding] = m.byEncoding[stats.ContentEncoding].add(s)
+}
+
+// Stats returns the aggregated statistics of the given content encoding (e.g. "gzip" or "gzip, br").
+func (m *MemoryObserver) Stats(contentEncoding string) EncodingStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.byEncoding[contentEncoding]
+}
+
+// Total returns the aggregated statistics of every content encoding.
+func (m *MemoryObserver) Total() EncodingStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := EncodingStats{}
+	for _, s := range m.byEncoding {
+		total = total.add(s)
+	}
+
+	return total
+}
+
+// Snapshot returns a copy of the aggregated statistics per content encoding.
+func (m *MemoryObserver) Snapshot() map[string]EncodingStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return maps.Clone(m.byEncoding)
+}
+
+// Reset clears the aggregated statistics.
+func (m *MemoryObserver) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.byEncoding = map[string]EncodingStats{}
+}
@@ -0,0 +1,148 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTripperObserver(t *testing.T) {
+	original := []byte(strings.Repeat("observe me. ", 1000))
+	gz := compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), original)
+	gzBR := compressBytes(t, NewBRBodyCompressorPool(defaultBRLevel), gz)
+	bomb := compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), make([]byte, 2<<20))
+
+	tests := map[string]struct {
+		ContentEncoding    string
+		Body               []byte
+		ExpectedEncoding   string
+		ExpectedStats      EncodingStats
+		ExpectedRoundTrip  bool
+		ExpectedReadError  bool
+		ExpectedNoObserved bool
+	}{
+		"gzip": {
+			ContentEncoding:   "gzip",
+			Body:              gz,
+			ExpectedEncoding:  "gzip",
+			ExpectedStats:     EncodingStats{Responses: 1, Compressed: int64(len(gz)), Decompressed: int64(len(original))},
+			ExpectedRoundTrip: true,
+		},
+		"layered": {
+			ContentEncoding:   "gzip, BR",
+			Body:              gzBR,
+			ExpectedEncoding:  "gzip, br",
+			ExpectedStats:     EncodingStats{Responses: 1, Compressed: int64(len(gzBR)), Decompressed: int64(len(original))},
+			ExpectedRoundTrip: true,
+		},
+		"decode error": {
+			ContentEncoding:   "gzip",
+			Body:              gz[:len(gz)/2],
+			ExpectedEncoding:  "gzip",
+			ExpectedStats:     EncodingStats{Responses: 1, Errors: 1, Compressed: int64(len(gz) / 2)},
+			ExpectedRoundTrip: true,
+			ExpectedReadError: true,
+		},
+		"limit exceeded": {
+			ContentEncoding:   "gzip",
+			Body:              bomb,
+			ExpectedEncoding:  "gzip",
+			ExpectedStats:     EncodingStats{Responses: 1, Errors: 1, Decompressed: 1 << 20},
+			ExpectedRoundTrip: true,
+			ExpectedReadError: true,
+		},
+		"unsupported": {
+			ContentEncoding:  "compress",
+			Body:             gz,
+			ExpectedEncoding: "compress",
+			ExpectedStats:    EncodingStats{Responses: 1, Errors: 1},
+		},
+		"not encoded": {
+			Body:               original,
+			ExpectedRoundTrip:  true,
+			ExpectedNoObserved: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			observer := NewMemoryObserver()
+
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := okResponse(req)
+				if tc.ContentEncoding != "" {
+					resp.Header.Set("Content-Encoding", tc.ContentEncoding)
+				}
+				resp.Body = io.NopCloser(bytes.NewReader(tc.Body))
+				return resp, nil
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test/", nil)
+			require.NoError(t, err)
+
+			rt := NewRoundTripper(next, WithObserver(observer), WithDecompressionLimits(1<<20, 0))
+			resp, err := rt.RoundTrip(req)
+			if !tc.ExpectedRoundTrip {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+
+				_, err = io.ReadAll(resp.Body)
+				if tc.ExpectedReadError {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+				_ = resp.Body.Close()
+				// closing twice reports once.
+				_ = resp.Body.Close()
+			}
+
+			if tc.ExpectedNoObserved {
+				assert.Empty(t, observer.Snapshot())
+				return
+			}
+
+			got := observer.Stats(tc.ExpectedEncoding)
+			if tc.ExpectedStats.Compressed == 0 && tc.ExpectedRoundTrip {
+				// the compressed bytes read depend on the decoder buffering.
+				tc.ExpectedStats.Compressed = got.Compressed
+			}
+			if tc.ExpectedStats.Decompressed == 0 {
+				tc.ExpectedStats.Decompressed = got.Decompressed
+			}
+			if tc.ExpectedRoundTrip {
+				assert.Positive(t, got.Duration)
+			}
+			tc.ExpectedStats.Duration = got.Duration
+
+			assert.Equal(t, tc.ExpectedStats, got)
+			assert.Equal(t, got, observer.Total())
+		})
+	}
+}
+
+func TestMemoryObserver(t *testing.T) {
+	m := NewMemoryObserver()
+	m.ObserveDecode(DecodeStats{ContentEncoding: "gzip", Compressed: 100, Decompressed: 1000})
+	m.ObserveDecode(DecodeStats{ContentEncoding: "gzip", Compressed: 50, Decompressed: 200, Err: io.ErrUnexpectedEOF})
+	m.ObserveDecode(DecodeStats{ContentEncoding: "zstd", Compressed: 10, Decompressed: 40})
+
+	gzip := m.Stats("gzip")
+	assert.Equal(t, EncodingStats{Responses: 2, Errors: 1, Compressed: 150, Decompressed: 1200}, gzip)
+	assert.Equal(t, int64(1050), gzip.Saved())
+	assert.InDelta(t, 8.0, gzip.Ratio(), 0.0001)
+
+	assert.Equal(t, EncodingStats{Responses: 3, Errors: 1, Compressed: 160, Decompressed: 1240}, m.Total())
+	assert.Len(t, m.Snapshot(), 2)
+	assert.Zero(t, EncodingStats{}.Ratio())
+
+	m.Reset()
+	assert.Equal(t, EncodingStats{}, m.Total())
+}
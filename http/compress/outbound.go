@@ -1,17 +1,43 @@
 package compress
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
+// NewRoundTripper returns a [RoundTripper] that decodes the response bodies (and optionally compresses the request bodies).
+// Invalid [WithAcceptEncodingPreferences] preferences are ignored and the Accept-Encoding header is generated from the
+// registered decoders, use [NewCheckedRoundTripper] to get the error instead.
 func NewRoundTripper(next http.RoundTripper, opts ...RoundTripperOption) *RoundTripper {
+	rt, _ := newRoundTripper(next, opts)
+
+	return rt
+}
+
+// NewCheckedRoundTripper is like [NewRoundTripper] but returns the error of invalid options, e.g.
+// [ErrInvalidEncodingPreferences].
+func NewCheckedRoundTripper(next http.RoundTripper, opts ...RoundTripperOption) (*RoundTripper, error) {
+	rt, err := newRoundTripper(next, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+// newRoundTripper always returns a usable round tripper, along with the error of the invalid options.
+func newRoundTripper(next http.RoundTripper, opts []RoundTripperOption) (*RoundTripper, error) {
 	rt := &RoundTripper{
 		next:                 next,
 		omitCondition:        nil,
 		acceptEncodingHeader: "",
 		contentDecoders:      map[string]BodyDecoder{},
+		requestMinSize:       DefaultMinSize,
+		requestMaxBufferSize: DefaultRequestCompressionMaxBufferSize,
 	}
 
 	for _, o := range opts {
@@ -22,13 +48,23 @@ func NewRoundTripper(next http.RoundTripper, opts ...RoundTripperOption) *RoundT
 		rt.defaultInit()
 	}
 
-	return rt
+	err := rt.initAcceptEncodingHeader()
+
+	return rt, err
 }
 
 type BodyDecoder interface {
 	WrapBody(body io.ReadCloser) io.ReadCloser
 }
 
+// BodyDecoderFunc is a [BodyDecoder] signature alias.
+type BodyDecoderFunc func(body io.ReadCloser) io.ReadCloser
+
+// WrapBody implements the BodyDecoder interface.
+func (f BodyDecoderFunc) WrapBody(body io.ReadCloser) io.ReadCloser {
+	return f(body)
+}
+
 const (
 	contentEncodingGZIP    = "gzip"
 	contentEncodingZSTD    = "zstd"
@@ -106,9 +142,42 @@ func WithCompressionTypeBR(useReaderPool bool) RoundTripperOption {
 	}
 }
 
+// WithAcceptEncoding sets the raw Accept-Encoding header value. Prefer [WithAcceptEncodingPreferences].
 func WithAcceptEncoding(ae string) RoundTripperOption {
 	return func(c *RoundTripper) {
-		c.acceptEncodingHeader = ae
+		c.acceptEncodingRaw = ae
+	}
+}
+
+// WithAcceptEncodingPreferences sets the weighted preferences the Accept-Encoding header is generated from
+// (e.g. Prefer("zstd", 1), Prefer("gzip", 0.5), NoIdentity()). The preferences are validated against the registered decoders,
+// an invalid set is ignored by [NewRoundTripper] and fails [NewCheckedRoundTripper] with [ErrInvalidEncodingPreferences].
+func WithAcceptEncodingPreferences(prefs ...EncodingPreference) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.acceptEncodingPreferences = prefs
+	}
+}
+
+// WithDecompressionLimits sets the decompression bomb protection limits that are applied on every decoded response body,
+// regardless of the decoder. Reading past a limit returns a [*DecompressionLimitError]. Zero value means no limit.
+func WithDecompressionLimits(maxBytes int64, maxRatio float64) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.limits = DecompressionLimits{MaxBytes: maxBytes, MaxRatio: maxRatio}
+	}
+}
+
+// WithCompressionDictionaries enables the Compression Dictionary Transport for zstd (dcz). For every request the first
+// matching dictionary of the store is advertised with the Available-Dictionary header, dcz is added to the Accept-Encoding
+// and a dcz response is decoded with the advertised dictionary.
+// Brotli shared dictionaries (dcb) are not supported, as the brotli decoder does not support custom dictionaries.
+func WithCompressionDictionaries(store *DictionaryStore, useReaderPool bool) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.dictionaries = store
+		if useReaderPool {
+			c.dictionaryDecoder = NewZSTDBodyDecompressorPool(WithDictionaries(store))
+		} else {
+			c.dictionaryDecoder = NewZSTDBodyDecompressor(WithDictionaries(store))
+		}
 	}
 }
 
@@ -119,12 +188,26 @@ func KeepContentHeaders() RoundTripperOption {
 }
 
 type RoundTripper struct {
-	next                 http.RoundTripper
-	omitCondition        OmitCondition
-	contentDecoders      map[string]BodyDecoder
-	acceptEncodingHeader string
-	contentEncodings     []string
-	keepHeaders          bool
+	next                      http.RoundTripper
+	omitCondition             OmitCondition
+	contentDecoders           map[string]BodyDecoder
+	acceptEncodingHeader      string
+	acceptEncodingRaw         string
+	acceptEncodingPreferences EncodingPreferences
+	contentEncodings          []string
+	keepHeaders               bool
+	limits                    DecompressionLimits
+
+	dictionaries      *DictionaryStore
+	dictionaryDecoder DictionaryBodyDecoder
+
+	observer Observer
+
+	requestContentEncoding string
+	requestEncoder         BodyEncoder
+	requestMinSize         int64
+	requestMaxBufferSize   int64
+	requestOmitCondition   OmitCondition
 }
 
 func (rt *RoundTripper) addDecoder(contentEncoding string, decoder BodyDecoder) {
@@ -133,15 +216,44 @@ func (rt *RoundTripper) addDecoder(contentEncoding string, decoder BodyDecoder)
 	rt.contentDecoders[contentEncoding] = decoder
 	if !exists {
 		rt.contentEncodings = append(rt.contentEncodings, contentEncoding)
-		rt.initAcceptEncodingHeader()
 	}
 }
 
-func (rt *RoundTripper) initAcceptEncodingHeader() {
-	rt.acceptEncodingHeader = strings.Join(rt.contentEncodings, ", ")
+// initAcceptEncodingHeader generates the Accept-Encoding header value from (in order of precedence) the raw value,
+// the preferences or the registered decoders. Invalid preferences are returned as error and the header falls back to the
+// registered decoders.
+func (rt *RoundTripper) initAcceptEncodingHeader() error {
+	if rt.acceptEncodingRaw != "" {
+		rt.acceptEncodingHeader = rt.acceptEncodingRaw
+		return nil
+	}
+
+	defaults := make(EncodingPreferences, 0, len(rt.contentEncodings))
+	for _, ce := range rt.contentEncodings {
+		defaults = append(defaults, Prefer(ce, 1))
+	}
+
+	if rt.acceptEncodingPreferences == nil {
+		rt.acceptEncodingHeader = defaults.String()
+		return nil
+	}
+
+	if err := rt.acceptEncodingPreferences.Validate(rt.contentEncodings); err != nil {
+		rt.acceptEncodingHeader = defaults.String()
+		return err
+	}
+
+	rt.acceptEncodingHeader = rt.acceptEncodingPreferences.String()
+
+	return nil
 }
 
 func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, err := rt.compressRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// check exclude conditions.
 	if rt.omit(req) {
 		return rt.next.RoundTrip(req)
@@ -152,19 +264,34 @@ func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		return rt.next.RoundTrip(req)
 	}
 	req.Header.Set(AcceptEncoding, rt.acceptEncodingHeader)
+	dict := rt.advertiseDictionary(req)
 
 	resp, err := rt.next.RoundTrip(req)
 	if err != nil {
 		return resp, err
 	}
 
-	contentEncoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
-	decompressor, exists := rt.contentDecoders[contentEncoding]
-	if !exists {
+	contentEncodings := parseContentEncoding(resp.Header.Values("Content-Encoding"))
+	if len(contentEncodings) == 0 {
 		return resp, nil
 	}
 
-	resp.Body = decompressor.WrapBody(resp.Body)
+	decoder, err := rt.layeredDecoder(contentEncodings, dict)
+	if err != nil {
+		_ = resp.Body.Close()
+		if rt.observer != nil {
+			rt.observer.ObserveDecode(DecodeStats{Request: req, ContentEncoding: strings.Join(contentEncodings, ", "), Err: err})
+		}
+		return nil, err
+	}
+
+	if rt.observer != nil {
+		resp.Body = observeBody(rt.observer, req, strings.Join(contentEncodings, ", "), resp.Body, func(body io.ReadCloser) io.ReadCloser {
+			return limitBody(rt.limits, body, decoder)
+		})
+	} else {
+		resp.Body = limitBody(rt.limits, resp.Body, decoder)
+	}
 
 	if !rt.keepHeaders {
 		resp.Header.Del("Content-Encoding")
@@ -177,6 +304,76 @@ func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+// advertiseDictionary advertises the first dictionary that matches the request (if any) and returns it.
+func (rt *RoundTripper) advertiseDictionary(req *http.Request) *Dictionary {
+	if rt.dictionaries == nil {
+		return nil
+	}
+
+	dict, exists := rt.dictionaries.Select(req)
+	if !exists {
+		return nil
+	}
+
+	req.Header.Set(AcceptEncoding, rt.acceptEncodingHeader+", "+contentEncodingDCZ)
+	req.Header.Set(AvailableDictionary, dict.Hash().StructuredField())
+	if dict.ID != "" {
+		req.Header.Set(DictionaryID, strconv.Quote(dict.ID))
+	}
+
+	return dict
+}
+
+// layeredDecoder returns a decoder that removes the content encoding layers in the reverse order they were applied.
+// dcz is decoded only with the advertised dictionary.
+func (rt *RoundTripper) layeredDecoder(contentEncodings []string, dict *Dictionary) (BodyDecoder, error) {
+	decoders := make([]BodyDecoder, 0, len(contentEncodings))
+	for _, ce := range contentEncodings {
+		d, exists := rt.contentDecoders[ce]
+		if !exists && ce == contentEncodingDCZ && dict != nil {
+			hash := dict.Hash()
+			d, exists = BodyDecoderFunc(func(body io.ReadCloser) io.ReadCloser {
+				return rt.dictionaryDecoder.WrapDictionaryBody(body, &hash)
+			}), true
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentEncoding, ce)
+		}
+		decoders = append(decoders, d)
+	}
+
+	if len(decoders) == 1 {
+		return decoders[0], nil
+	}
+
+	return BodyDecoderFunc(func(body io.ReadCloser) io.ReadCloser {
+		for i := len(decoders) - 1; i >= 0; i-- {
+			body = decoders[i].WrapBody(body)
+		}
+
+		return body
+	}), nil
+}
+
+var ErrUnsupportedContentEncoding = errors.New("unsupported response content encoding")
+
+// parseContentEncoding parses the (comma separated) Content-Encoding header values, in the order they were applied.
+// Codings are lower cased and identity is ignored.
+func parseContentEncoding(values []string) []string {
+	var encodings []string
+	for _, v := range values {
+		for ce := range strings.SplitSeq(v, ",") {
+			ce = strings.ToLower(strings.TrimSpace(ce))
+			if ce == "" || ce == "identity" {
+				continue
+			}
+			encodings = append(encodings, ce)
+		}
+	}
+
+	return encodings
+}
+
 func (rt *RoundTripper) omit(req *http.Request) bool {
 	return req.Header.Get(AcceptEncoding) != "" ||
 		req.Header.Get("Range") != "" ||
@@ -0,0 +1,129 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithRequestCompression enables the compression of the request bodies using the given content encoding and encoder.
+// Bodies smaller than the min size ([WithRequestCompressionMinSize]) are sent as is.
+func WithRequestCompression(contentEncoding string, encoder BodyEncoder) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.requestContentEncoding = strings.ToLower(contentEncoding)
+		c.requestEncoder = encoder
+	}
+}
+
+func WithRequestCompressionGZIP(level int) RoundTripperOption {
+	return WithRequestCompression(contentEncodingGZIP, NewGZIPBodyCompressorPool(level))
+}
+
+func WithRequestCompressionZSTD(level int) RoundTripperOption {
+	return WithRequestCompression(contentEncodingZSTD, NewZSTDBodyCompressorPool(level))
+}
+
+func WithRequestCompressionBR(level int) RoundTripperOption {
+	return WithRequestCompression(contentEncodingBR, NewBRBodyCompressorPool(level))
+}
+
+// DefaultRequestCompressionMaxBufferSize is the default maximum request body size (in bytes) that is compressed in memory.
+const DefaultRequestCompressionMaxBufferSize = 1 << 20
+
+// WithRequestCompressionMinSize sets the minimum request body size (in bytes) that will be compressed.
+// Requests with unknown content length are always compressed.
+func WithRequestCompressionMinSize(minSize int64) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.requestMinSize = max(minSize, 0)
+	}
+}
+
+// WithRequestCompressionMaxBufferSize sets the maximum request body size (in bytes) that is compressed in memory
+// (default [DefaultRequestCompressionMaxBufferSize]). Larger bodies, and bodies of unknown length, are compressed while
+// they are sent, with an unknown ContentLength.
+func WithRequestCompressionMaxBufferSize(maxSize int64) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.requestMaxBufferSize = max(maxSize, 0)
+	}
+}
+
+// WithRequestCompressionOmitCondition sets a condition that, when true, disables the request body compression (e.g. for specific hosts).
+func WithRequestCompressionOmitCondition(ec OmitCondition) RoundTripperOption {
+	return func(c *RoundTripper) {
+		c.requestOmitCondition = ec
+	}
+}
+
+// compressRequest returns a clone of the request with the compressed body, or the request itself when compression is omitted.
+// A body of known length up to the max buffer size is buffered, so that the ContentLength is known and GetBody is able to
+// replay it (retries / redirects). Any other body is streamed through the encoder, and replayed only if the request is.
+func (rt *RoundTripper) compressRequest(req *http.Request) (*http.Request, error) {
+	if rt.omitRequestCompression(req) {
+		return req, nil
+	}
+
+	// for client requests a zero ContentLength with a body means unknown length.
+	if req.ContentLength <= 0 || req.ContentLength > rt.requestMaxBufferSize {
+		out := req.Clone(req.Context())
+		out.Body = rt.compressStream(req.Body)
+		out.GetBody = nil
+		if getBody := req.GetBody; getBody != nil {
+			out.GetBody = func() (io.ReadCloser, error) {
+				b, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+
+				return rt.compressStream(b), nil
+			}
+		}
+		out.ContentLength = -1
+		out.Header.Del("Content-Length")
+		out.Header.Set("Content-Encoding", rt.requestContentEncoding)
+
+		return out, nil
+	}
+
+	buf := &bytes.Buffer{}
+	ew := rt.requestEncoder.WrapWriter(buf)
+	_, copyErr := io.Copy(ew, req.Body)
+	if err := errors.Join(copyErr, ew.Close(), req.Body.Close()); err != nil {
+		return nil, err
+	}
+
+	compressed := buf.Bytes()
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(compressed))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	out.ContentLength = int64(len(compressed))
+	out.Header.Del("Content-Length")
+	out.Header.Set("Content-Encoding", rt.requestContentEncoding)
+
+	return out, nil
+}
+
+// compressStream returns a body that yields the compressed bytes of the given body, as they are read.
+func (rt *RoundTripper) compressStream(body io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		ew := rt.requestEncoder.WrapWriter(pw)
+		_, copyErr := io.Copy(ew, body)
+		_ = pw.CloseWithError(errors.Join(copyErr, ew.Close(), body.Close()))
+	}()
+
+	return pr
+}
+
+func (rt *RoundTripper) omitRequestCompression(req *http.Request) bool {
+	return rt.requestEncoder == nil ||
+		req.Body == nil || req.Body == http.NoBody ||
+		req.Header.Get("Content-Encoding") != "" ||
+		(req.ContentLength > 0 && req.ContentLength < rt.requestMinSize) ||
+		(rt.requestOmitCondition != nil && rt.requestOmitCondition(req))
+}
@@ -0,0 +1,231 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (rt roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt(r)
+}
+
+func okResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+func TestRoundTripperRequestCompression(t *testing.T) {
+	largeBody := strings.Repeat("compress me please. ", 200)
+	decoders := map[string]BodyDecoder{
+		"gzip": NewGZIPBodyDecompressor(),
+		"br":   NewBRBodyDecompressor(),
+		"zstd": NewZSTDBodyDecompressor(),
+	}
+
+	tests := map[string]struct {
+		Options                 []RoundTripperOption
+		URL                     string
+		Body                    func() io.Reader
+		ContentEncoding         string
+		ExpectedContentEncoding string
+		ExpectedStreamed        bool
+	}{
+		"gzip": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel)},
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ExpectedContentEncoding: "gzip",
+		},
+		"zstd": {
+			Options:                 []RoundTripperOption{WithRequestCompressionZSTD(defaultZSTDLevel)},
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ExpectedContentEncoding: "zstd",
+		},
+		"br": {
+			Options:                 []RoundTripperOption{WithRequestCompressionBR(defaultBRLevel)},
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ExpectedContentEncoding: "br",
+		},
+		"unknown length": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel)},
+			Body:                    func() io.Reader { return io.MultiReader(strings.NewReader("ab")) },
+			ExpectedContentEncoding: "gzip",
+			ExpectedStreamed:        true,
+		},
+		"above max buffer size": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel), WithRequestCompressionMaxBufferSize(2000)},
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ExpectedContentEncoding: "gzip",
+			ExpectedStreamed:        true,
+		},
+		"not enabled": {
+			Options:                 nil,
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ExpectedContentEncoding: "",
+		},
+		"below min size": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel)},
+			Body:                    func() io.Reader { return strings.NewReader("small") },
+			ExpectedContentEncoding: "",
+		},
+		"custom min size": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel), WithRequestCompressionMinSize(1)},
+			Body:                    func() io.Reader { return strings.NewReader("small") },
+			ExpectedContentEncoding: "gzip",
+		},
+		"no body": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel)},
+			Body:                    func() io.Reader { return nil },
+			ExpectedContentEncoding: "",
+		},
+		"already encoded": {
+			Options:                 []RoundTripperOption{WithRequestCompressionGZIP(defaultGZIPLevel)},
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ContentEncoding:         "custom",
+			ExpectedContentEncoding: "custom",
+		},
+		"omit condition": {
+			Options: []RoundTripperOption{
+				WithRequestCompressionGZIP(defaultGZIPLevel),
+				WithRequestCompressionOmitCondition(func(req *http.Request) bool { return req.URL.Host == "legacy.test" }),
+			},
+			URL:                     "https://legacy.test/upload",
+			Body:                    func() io.Reader { return strings.NewReader(largeBody) },
+			ExpectedContentEncoding: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			u := tc.URL
+			if u == "" {
+				u = "https://domain.test/upload"
+			}
+
+			body := tc.Body()
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, u, body)
+			require.NoError(t, err)
+			if tc.ContentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.ContentEncoding)
+			}
+
+			var sent *http.Request
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				sent = req
+				return okResponse(req), nil
+			})
+
+			resp, err := NewRoundTripper(next, tc.Options...).RoundTrip(req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+			require.NotNil(t, sent)
+
+			assert.Equal(t, tc.ExpectedContentEncoding, sent.Header.Get("Content-Encoding"))
+			if body == nil {
+				return
+			}
+
+			decoder, exists := decoders[tc.ExpectedContentEncoding]
+			if !exists {
+				return
+			}
+
+			// the original request is not modified.
+			assert.Empty(t, req.Header.Get("Content-Encoding"))
+
+			compressed, err := io.ReadAll(sent.Body)
+			require.NoError(t, err)
+			require.NoError(t, sent.Body.Close())
+			if tc.ExpectedStreamed {
+				assert.Equal(t, int64(-1), sent.ContentLength)
+			} else {
+				assert.Equal(t, int64(len(compressed)), sent.ContentLength)
+			}
+
+			// GetBody replays the compressed body, when the original request is replayable.
+			if req.GetBody == nil {
+				assert.Nil(t, sent.GetBody)
+			} else {
+				require.NotNil(t, sent.GetBody)
+				replay, err := sent.GetBody()
+				require.NoError(t, err)
+				replayed, err := io.ReadAll(replay)
+				require.NoError(t, err)
+				assert.Equal(t, compressed, replayed)
+			}
+
+			rc := decoder.WrapBody(io.NopCloser(bytes.NewReader(compressed)))
+			got, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			expected, _ := io.ReadAll(tc.Body())
+			assert.Equal(t, string(expected), string(got))
+		})
+	}
+}
+
+func TestRoundTripperContentEncoding(t *testing.T) {
+	original := []byte(strings.Repeat("layered ", 500))
+	gz := compressBytes(t, NewGZIPBodyCompressorPool(defaultGZIPLevel), original)
+	gzBR := compressBytes(t, NewBRBodyCompressorPool(defaultBRLevel), gz)
+	gzBRZSTD := compressBytes(t, NewZSTDBodyCompressorPool(defaultZSTDLevel), gzBR)
+
+	tests := map[string]struct {
+		ContentEncoding []string
+		Body            []byte
+		ExpectedBody    []byte
+		ExpectedError   error
+	}{
+		"single":               {ContentEncoding: []string{"gzip"}, Body: gz, ExpectedBody: original},
+		"identity":             {ContentEncoding: []string{"identity"}, Body: original, ExpectedBody: original},
+		"two layers":           {ContentEncoding: []string{"gzip, br"}, Body: gzBR, ExpectedBody: original},
+		"case and whitespace":  {ContentEncoding: []string{" GZIP ,Br "}, Body: gzBR, ExpectedBody: original},
+		"with identity":        {ContentEncoding: []string{"gzip, identity, br"}, Body: gzBR, ExpectedBody: original},
+		"three layers":         {ContentEncoding: []string{"gzip, br, zstd"}, Body: gzBRZSTD, ExpectedBody: original},
+		"multiple header line": {ContentEncoding: []string{"gzip", "br, zstd"}, Body: gzBRZSTD, ExpectedBody: original},
+		"unknown layer":        {ContentEncoding: []string{"gzip, compress"}, Body: gz, ExpectedError: ErrUnsupportedContentEncoding},
+		"unknown single":       {ContentEncoding: []string{"compress"}, Body: gz, ExpectedError: ErrUnsupportedContentEncoding},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := okResponse(req)
+				for _, ce := range tc.ContentEncoding {
+					resp.Header.Add("Content-Encoding", ce)
+				}
+				resp.Body = io.NopCloser(bytes.NewReader(tc.Body))
+				return resp, nil
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://domain.test/", nil)
+			require.NoError(t, err)
+
+			resp, err := NewRoundTripper(next, WithCompressionTypeGZIP(true), WithCompressionTypeBR(true), WithCompressionTypeZSTD(false)).RoundTrip(req)
+			if tc.ExpectedError != nil {
+				require.ErrorIs(t, err, tc.ExpectedError)
+				assert.Nil(t, resp)
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, string(tc.ExpectedBody), string(got))
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package compress
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	_ http.ResponseWriter = (*compressResponseWriter)(nil)
+	_ http.Flusher        = (*compressResponseWriter)(nil)
+)
+
+// compressResponseWriter buffers the first bytes of the response body until it is able to decide if the
+// response should be compressed (status code, Content-Encoding, Content-Type, Content-Length and minimum size).
+type compressResponseWriter struct {
+	w                http.ResponseWriter
+	contentEncoding  string
+	encoder          BodyEncoder
+	minSize          int
+	compressibleType func(contentType string) bool
+
+	buf         []byte
+	statusCode  int
+	wroteHeader bool
+	decided     bool
+	encodeW     EncodeWriter
+
+	// contentLengthReachesMinSize is true when the Content-Length header is at least the min size, in which case the
+	// decision is made on the first write (once there are bytes to sniff the content type from).
+	contentLengthReachesMinSize bool
+}
+
+func (c *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
+
+func (c *compressResponseWriter) Header() http.Header {
+	return c.w.Header()
+}
+
+func (c *compressResponseWriter) WriteHeader(statusCode int) {
+	// informational headers are sent as is.
+	if statusCode >= 100 && statusCode <= 199 && statusCode != http.StatusSwitchingProtocols {
+		c.w.WriteHeader(statusCode)
+		return
+	}
+
+	if c.wroteHeader {
+		return
+	}
+
+	c.wroteHeader = true
+	c.statusCode = statusCode
+
+	if !c.shouldCompress() {
+		c.commit(false)
+		return
+	}
+
+	if cl, err := strconv.ParseInt(c.Header().Get("Content-Length"), 10, 64); err == nil && cl >= int64(c.minSize) {
+		c.contentLengthReachesMinSize = true
+	}
+}
+
+func (c *compressResponseWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.decided {
+		return c.write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= c.minSize || c.contentLengthReachesMinSize {
+		c.commit(true)
+		if err := c.writeBuffer(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (c *compressResponseWriter) Flush() {
+	_ = c.FlushError()
+}
+
+// FlushError flushes the buffered (and the encoder's pending) data to the client.
+// A flush before the compression decision is made is treated as a streaming response, thus compressed regardless of the min size.
+func (c *compressResponseWriter) FlushError() error {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if !c.decided {
+		c.commit(true)
+		if err := c.writeBuffer(); err != nil {
+			return err
+		}
+	}
+
+	var encErr error
+	if c.encodeW != nil {
+		encErr = c.encodeW.Flush()
+	}
+
+	return errors.Join(encErr, http.NewResponseController(c.w).Flush())
+}
+
+// Close finalizes the response. Pending buffered data are written (uncompressed if they never reached the min size)
+// and the encoder is closed and returned to its pool.
+func (c *compressResponseWriter) Close() error {
+	if !c.wroteHeader {
+		return nil
+	}
+
+	if !c.decided {
+		c.commit(false)
+	}
+
+	bufErr := c.writeBuffer()
+
+	if c.encodeW == nil {
+		return bufErr
+	}
+
+	return errors.Join(bufErr, c.encodeW.Close())
+}
+
+func (c *compressResponseWriter) shouldCompress() bool {
+	switch {
+	case c.statusCode < http.StatusOK,
+		c.statusCode == http.StatusNoContent,
+		c.statusCode == http.StatusResetContent,
+		c.statusCode == http.StatusPartialContent,
+		c.statusCode == http.StatusNotModified:
+		return false
+	}
+
+	h := c.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if ct := h.Get("Content-Type"); ct != "" && c.compressibleType != nil && !c.compressibleType(ct) {
+		return false
+	}
+
+	if cl, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil && cl < int64(c.minSize) {
+		return false
+	}
+
+	return true
+}
+
+// commit writes the response header, setting the compression related headers when compress is true.
+// When compress is true the final decision might still be negative, if the sniffed content type is not compressible.
+func (c *compressResponseWriter) commit(compress bool) {
+	c.decided = true
+
+	h := c.Header()
+
+	if compress {
+		if h.Get("Content-Type") == "" && len(c.buf) > 0 {
+			// net/http would sniff the compressed bytes, so detect the content type on the uncompressed ones.
+			h.Set("Content-Type", http.DetectContentType(c.buf))
+		}
+
+		if ct := h.Get("Content-Type"); ct != "" && c.compressibleType != nil && !c.compressibleType(ct) {
+			compress = false
+		}
+	}
+
+	if compress {
+		h.Set("Content-Encoding", c.contentEncoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+
+		// the representation changes, so a strong validator can not be kept.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		c.encodeW = c.encoder.WrapWriter(c.w)
+	}
+
+	c.w.WriteHeader(c.statusCode)
+}
+
+func (c *compressResponseWriter) writeBuffer() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+
+	_, err := c.write(c.buf)
+	c.buf = nil
+
+	return err
+}
+
+func (c *compressResponseWriter) write(p []byte) (int, error) {
+	if c.encodeW != nil {
+		return c.encodeW.Write(p)
+	}
+
+	return c.w.Write(p)
+}
@@ -9,13 +9,15 @@ import (
 
 const defaultZSTDDecoderMaxWindow = 128 << 20
 
-func defaultZSTDDecoder(r io.Reader) (*zstd.Decoder, error) {
-	return zstd.NewReader(
-		r,
-		zstd.WithDecoderLowmem(true),
-		zstd.WithDecoderMaxWindow(defaultZSTDDecoderMaxWindow),
-		zstd.WithDecoderConcurrency(1),
-	)
+func newZSTDDecoder(r io.Reader, o ZSTDDecoderOptions) (*zstd.Decoder, error) {
+	return zstd.NewReader(r, o.decoderOptions(0)...)
+}
+
+// newZSTDDictionaryDecoder returns a decoder of a dictionary compressed (dcz) stream. The window is allowed to grow up to
+// 1.25 times the dictionary size, as the stream may reference the whole dictionary.
+func newZSTDDictionaryDecoder(r io.Reader, o ZSTDDecoderOptions, dict *Dictionary) (*zstd.Decoder, error) {
+	opts := append(o.decoderOptions(uint64(len(dict.Content))*5/4), zstd.WithDecoderDictRaw(0, dict.Content))
+	return zstd.NewReader(r, opts...)
 }
 
 type zstdDecoderWrapper struct {
@@ -25,19 +27,33 @@ type zstdDecoderWrapper struct {
 
 type ZSTDBodyDecompressorPool struct {
 	readerPool sync.Pool
+	config     decompressorConfig
+	// dictionaryPools holds a decoders pool (*sync.Pool) per dictionary hash.
+	dictionaryPools sync.Map
 }
 
-func NewZSTDBodyDecompressorPool() *ZSTDBodyDecompressorPool {
+func NewZSTDBodyDecompressorPool(opts ...DecompressorOption) *ZSTDBodyDecompressorPool {
+	config := newDecompressorConfig(opts)
 	return &ZSTDBodyDecompressorPool{
+		config: config,
 		readerPool: sync.Pool{New: func() any {
 			w := &zstdDecoderWrapper{}
-			w.decoder, w.initError = defaultZSTDDecoder(nil)
+			o, err := config.zstd.pooled()
+			if err != nil {
+				w.initError = err
+				return w
+			}
+			w.decoder, w.initError = newZSTDDecoder(nil, o)
 			return w
 		}},
 	}
 }
 
 func (d *ZSTDBodyDecompressorPool) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *ZSTDBodyDecompressorPool) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
 	return &decompressorBodyWrapper[*zstd.Decoder]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*zstd.Decoder, error) {
@@ -54,18 +70,144 @@ func (d *ZSTDBodyDecompressorPool) WrapBody(compressBody io.ReadCloser) io.ReadC
 	}
 }
 
-type ZSTDBodyDecompressor struct{}
+// WrapDictionaryBody implements the [DictionaryBodyDecoder] interface, it decodes a dcz body using the dictionaries
+// set by [WithDictionaries].
+func (d *ZSTDBodyDecompressorPool) WrapDictionaryBody(compressBody io.ReadCloser, expected *DictionaryHash) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(func(compressBody io.ReadCloser) io.ReadCloser {
+		var pool *sync.Pool
 
-func NewZSTDBodyDecompressor() *ZSTDBodyDecompressor {
-	return &ZSTDBodyDecompressor{}
+		return &decompressorBodyWrapper[*zstd.Decoder]{
+			CompressedBody: compressBody,
+			GetDecoderFn: func(compressedBody io.ReadCloser) (*zstd.Decoder, error) {
+				dict, err := readDictionaryHeader(compressedBody, dczMagic, d.config.dictionaries, expected)
+				if err != nil {
+					return nil, err
+				}
+
+				pool = d.dictionaryPool(dict)
+				w, _ := pool.Get().(*zstdDecoderWrapper)
+				if w.initError != nil {
+					return nil, w.initError
+				}
+				return w.decoder, w.decoder.Reset(compressedBody)
+			},
+			ReturnDecoderFn: func(decoder *zstd.Decoder) error {
+				pool.Put(&zstdDecoderWrapper{decoder: decoder, initError: nil})
+				return nil
+			},
+		}
+	}))
+}
+
+func (d *ZSTDBodyDecompressorPool) dictionaryPool(dict *Dictionary) *sync.Pool {
+	p, exists := d.dictionaryPools.Load(dict.Hash())
+	if !exists {
+		p, _ = d.dictionaryPools.LoadOrStore(dict.Hash(), &sync.Pool{New: func() any {
+			w := &zstdDecoderWrapper{}
+			o, err := d.config.zstd.pooled()
+			if err != nil {
+				w.initError = err
+				return w
+			}
+			w.decoder, w.initError = newZSTDDictionaryDecoder(nil, o, dict)
+			return w
+		}})
+	}
+
+	pool, _ := p.(*sync.Pool)
+	return pool
+}
+
+type ZSTDBodyDecompressor struct {
+	config decompressorConfig
+}
+
+func NewZSTDBodyDecompressor(opts ...DecompressorOption) *ZSTDBodyDecompressor {
+	return &ZSTDBodyDecompressor{
+		config: newDecompressorConfig(opts),
+	}
 }
 
 func (d *ZSTDBodyDecompressor) WrapBody(compressBody io.ReadCloser) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(d.wrapBody))
+}
+
+func (d *ZSTDBodyDecompressor) wrapBody(compressBody io.ReadCloser) io.ReadCloser {
 	return &decompressorBodyWrapper[*zstd.Decoder]{
 		CompressedBody: compressBody,
 		GetDecoderFn: func(compressedBody io.ReadCloser) (*zstd.Decoder, error) {
-			return defaultZSTDDecoder(compressedBody)
+			return newZSTDDecoder(compressedBody, d.config.zstd)
+		},
+		ReturnDecoderFn: func(decoder *zstd.Decoder) error {
+			decoder.Close()
+			return nil
+		},
+	}
+}
+
+// WrapDictionaryBody implements the [DictionaryBodyDecoder] interface, it decodes a dcz body using the dictionaries
+// set by [WithDictionaries].
+func (d *ZSTDBodyDecompressor) WrapDictionaryBody(compressBody io.ReadCloser, expected *DictionaryHash) io.ReadCloser {
+	return limitBody(d.config.limits, compressBody, BodyDecoderFunc(func(compressBody io.ReadCloser) io.ReadCloser {
+		return &decompressorBodyWrapper[*zstd.Decoder]{
+			CompressedBody: compressBody,
+			GetDecoderFn: func(compressedBody io.ReadCloser) (*zstd.Decoder, error) {
+				dict, err := readDictionaryHeader(compressedBody, dczMagic, d.config.dictionaries, expected)
+				if err != nil {
+					return nil, err
+				}
+				return newZSTDDictionaryDecoder(compressedBody, d.config.zstd, dict)
+			},
+			ReturnDecoderFn: func(decoder *zstd.Decoder) error {
+				decoder.Close()
+				return nil
+			},
+		}
+	}))
+}
+
+func defaultZSTDEncoder(w io.Writer, level int) (*zstd.Encoder, error) {
+	return zstd.NewWriter(
+		w,
+		zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+		zstd.WithEncoderConcurrency(1),
+	)
+}
+
+type zstdEncoderWrapper struct {
+	encoder   *zstd.Encoder
+	initError error
+}
+
+type ZSTDBodyCompressorPool struct {
+	writerPool sync.Pool
+}
+
+// NewZSTDBodyCompressorPool returns a pooled zstd [BodyEncoder] that uses the given zstd compression level (1-22).
+func NewZSTDBodyCompressorPool(level int) *ZSTDBodyCompressorPool {
+	return &ZSTDBodyCompressorPool{
+		writerPool: sync.Pool{New: func() any {
+			w := &zstdEncoderWrapper{}
+			w.encoder, w.initError = defaultZSTDEncoder(nil, level)
+			return w
+		}},
+	}
+}
+
+func (c *ZSTDBodyCompressorPool) WrapWriter(w io.Writer) EncodeWriter {
+	return &compressorWriterWrapper[*zstd.Encoder]{
+		Writer: w,
+		GetEncoderFn: func(w io.Writer) (*zstd.Encoder, error) {
+			ew, _ := c.writerPool.Get().(*zstdEncoderWrapper)
+			if ew.initError != nil {
+				return nil, ew.initError
+			}
+			ew.encoder.Reset(w)
+			return ew.encoder, nil
+		},
+		ReturnEncoderFn: func(encoder *zstd.Encoder) error {
+			c.writerPool.Put(&zstdEncoderWrapper{encoder: encoder, initError: nil})
+			return nil
 		},
-		ReturnDecoderFn: func(_ *zstd.Decoder) error { return nil },
 	}
 }
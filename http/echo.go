@@ -2,16 +2,55 @@ package http
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifnotnil/x/http/compress"
+	"github.com/ifnotnil/x/http/encoding"
 )
 
-func EchoHandler(logger *slog.Logger) func(http.ResponseWriter, *http.Request) {
+type EchoOption func(c *echoConfig)
+
+type echoConfig struct {
+	queryControl bool
+	maxDelay     time.Duration
+}
+
+// WithEchoQueryControl lets the request query control the response:
+//   - delay: a delay before responding (duration e.g. 250ms, or milliseconds), capped to maxDelay.
+//   - status: the response status code.
+//   - header: a "Name: value" response header, can be repeated. Content-Type, Content-Length and Transfer-Encoding
+//     are set by the handler and rejected.
+func WithEchoQueryControl(maxDelay time.Duration) EchoOption {
+	return func(c *echoConfig) {
+		c.queryControl = true
+		c.maxDelay = maxDelay
+	}
+}
+
+// EchoHandler responds with a json representation of the request ([UnpackRequest]).
+func EchoHandler(logger *slog.Logger, opts ...EchoOption) func(http.ResponseWriter, *http.Request) {
+	cfg := &echoConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func(ctx context.Context) {
 			err := DrainAndCloseRequest(r)
@@ -20,10 +59,30 @@ func EchoHandler(logger *slog.Logger) func(http.ResponseWriter, *http.Request) {
 			}
 		}(r.Context())
 
+		status := http.StatusOK
+		if cfg.queryControl {
+			var err error
+			status, err = cfg.applyQuery(w, r)
+			if errors.Is(err, ErrEchoInvalidQuery) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				// the request context is done during the delay.
+				return
+			}
+		}
+
+		// these statuses do not allow a body.
+		if status == http.StatusNoContent || status == http.StatusNotModified {
+			w.WriteHeader(status)
+			return
+		}
+
 		b, _ := UnpackRequest(r)
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
 		err := json.NewEncoder(w).Encode(b)
 		if err != nil {
 			logger.ErrorContext(r.Context(), "error during json encoding", slog.String("error", err.Error()))
@@ -31,6 +90,69 @@ func EchoHandler(logger *slog.Logger) func(http.ResponseWriter, *http.Request) {
 	}
 }
 
+// applyQuery validates the query, then sets the response headers, waits the delay and returns the status code requested by it.
+func (c *echoConfig) applyQuery(w http.ResponseWriter, r *http.Request) (int, error) {
+	q := r.URL.Query()
+
+	status := http.StatusOK
+	if v := q.Get("status"); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 200 || code > 599 {
+			return 0, fmt.Errorf("%w: status %q", ErrEchoInvalidQuery, v)
+		}
+		status = code
+	}
+
+	header := http.Header{}
+	for _, h := range q["header"] {
+		name, value, found := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || echoReservedHeader(name) {
+			return 0, fmt.Errorf("%w: header %q", ErrEchoInvalidQuery, h)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+
+	var delay time.Duration
+	if v := q.Get("delay"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			ms, msErr := strconv.Atoi(v)
+			if msErr != nil {
+				return 0, fmt.Errorf("%w: delay %q", ErrEchoInvalidQuery, v)
+			}
+			d = time.Duration(ms) * time.Millisecond
+		}
+		delay = min(d, c.maxDelay)
+	}
+
+	for name, values := range header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+
+	if delay > 0 {
+		if err := sleep(r.Context(), delay); err != nil {
+			return 0, err
+		}
+	}
+
+	return status, nil
+}
+
+// echoReservedHeader reports whether the header is set by the handler itself, so it can not be requested by the query.
+func echoReservedHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Content-Type", "Content-Length", "Transfer-Encoding":
+		return true
+	default:
+		return false
+	}
+}
+
+var ErrEchoInvalidQuery = errors.New("echo: invalid query parameter")
+
 func UnpackRequest(r *http.Request) (map[string]any, error) {
 	body, bodyErr := UnpackRequestBody(r.Header, r.Body)
 	exp := map[string]any{
@@ -95,22 +217,259 @@ func UnpackHeaders(h http.Header) map[string]string {
 	return exp
 }
 
+// UnpackRequestBody decodes the request body according to the Content-Encoding (gzip, deflate, br and zstd) and the
+// Content-Type headers:
+//   - application/json and +json: the json value.
+//   - application/x-ndjson: an array of the json values.
+//   - application/xml, text/xml and +xml: the root element as {name, namespace, attributes, text, children}.
+//   - application/x-www-form-urlencoded: the form values.
+//   - multipart/form-data: {fields, files}, the files hold the metadata and the sha256 digest of the uploaded files.
+//   - text/*: the text.
+//
+// Every other type is base64 (raw url) encoded. The textual bodies are decoded into UTF-8 from their charset parameter.
+// The body is read but not closed.
 func UnpackRequestBody(h http.Header, body io.ReadCloser) (any, error) {
-	ct := h.Get("Content-Type")
+	if body == nil {
+		return "", nil
+	}
+
+	decoded, err := decodeContentEncoding(h.Values("Content-Encoding"), body)
+	if err != nil {
+		b, _ := unpackBase64(body)
+		return b, err
+	}
+	defer decoded.Close()
 
-	if strings.HasPrefix(ct, "application/json") {
-		b := map[string]any{}
-		return b, json.NewDecoder(body).Decode(&b)
+	mediaType, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+
+	switch {
+	case mediaType == "multipart/form-data":
+		return unpackMultipart(decoded, params["boundary"])
+	case textualMediaType(mediaType) && mediaType != "":
+		r, err := charsetReader(params["charset"], decoded)
+		if err != nil {
+			b, _ := unpackBase64(decoded)
+			return b, err
+		}
+
+		return unpackText(mediaType, params["charset"] != "", r)
+	default:
+		return unpackBase64(decoded)
+	}
+}
+
+var (
+	ErrEchoUnsupportedContentEncoding = errors.New("echo: unsupported content encoding")
+	ErrEchoMultipartBoundary          = errors.New("echo: multipart boundary is missing")
+)
+
+var echoBodyDecoders = sync.OnceValue(func() map[string]compress.BodyDecoder {
+	limit := compress.WithMaxDecompressedBytes(compress.DefaultMaxDecompressedSize)
+	gz := compress.NewGZIPBodyDecompressorPool(limit)
+
+	return map[string]compress.BodyDecoder{
+		"gzip":    gz,
+		"x-gzip":  gz,
+		"deflate": compress.NewFlateBodyDecompressorPool(limit),
+		"br":      compress.NewBRBodyDecompressorPool(limit),
+		"zstd":    compress.NewZSTDBodyDecompressorPool(limit),
+	}
+})
+
+// decodeContentEncoding wraps the body with the decoders of the content encodings, in the reverse order they were
+// applied. Closing the returned body releases the decoders without closing the original body.
+func decodeContentEncoding(values []string, body io.ReadCloser) (io.ReadCloser, error) {
+	var encodings []string
+	for _, v := range values {
+		for e := range strings.SplitSeq(v, ",") {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
 	}
 
-	if strings.HasPrefix(ct, "text/") {
-		b, err := io.ReadAll(body)
+	decoders := echoBodyDecoders()
+	for _, e := range encodings {
+		if _, exists := decoders[e]; !exists {
+			return nil, fmt.Errorf("%w: %q", ErrEchoUnsupportedContentEncoding, e)
+		}
+	}
+
+	decoded := io.NopCloser(body)
+	for _, e := range slices.Backward(encodings) {
+		decoded = decoders[e].WrapBody(decoded)
+	}
+
+	return decoded, nil
+}
+
+// charsetReader converts the reader from the charset into UTF-8.
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	if charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "us-ascii") {
+		return r, nil
+	}
+
+	enc, err := encoding.FromCharset(charset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, charset)
+	}
+
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// unpackText decodes the (UTF-8) textual media types. When the charset is set by the content type, the encoding
+// declared by an xml body is ignored.
+func unpackText(mediaType string, hasCharset bool, r io.Reader) (any, error) {
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		var v any
+		return v, json.NewDecoder(r).Decode(&v)
+	case mediaType == "application/x-ndjson":
+		return unpackNDJSON(r)
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		return unpackXML(r, hasCharset)
+	case mediaType == "application/x-www-form-urlencoded":
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		values, err := url.ParseQuery(string(b))
+		return map[string][]string(values), err
+	default:
+		b, err := io.ReadAll(r)
 		return string(b), err
 	}
+}
+
+func unpackNDJSON(r io.Reader) (any, error) {
+	values := []any{}
+	dec := json.NewDecoder(r)
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+}
+
+func unpackXML(r io.Reader, hasCharset bool) (any, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if hasCharset {
+			return input, nil
+		}
+		return charsetReader(charset, input)
+	}
+
+	var root map[string]any
+	var stack []map[string]any
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return root, nil
+		}
+		if err != nil {
+			return root, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			el := map[string]any{"name": t.Name.Local}
+			if t.Name.Space != "" {
+				el["namespace"] = t.Name.Space
+			}
+			if len(t.Attr) > 0 {
+				attrs := make(map[string]string, len(t.Attr))
+				for _, a := range t.Attr {
+					name := a.Name.Local
+					if a.Name.Space != "" {
+						name = a.Name.Space + ":" + name
+					}
+					attrs[name] = a.Value
+				}
+				el["attributes"] = attrs
+			}
+
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				children, _ := parent["children"].([]any)
+				parent["children"] = append(children, el)
+			} else if root == nil {
+				root = el
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" && len(stack) > 0 {
+				el := stack[len(stack)-1]
+				prev, _ := el["text"].(string)
+				el["text"] = prev + text
+			}
+		}
+	}
+}
+
+func unpackMultipart(r io.Reader, boundary string) (any, error) {
+	if boundary == "" {
+		return nil, ErrEchoMultipartBoundary
+	}
+
+	fields := map[string][]string{}
+	files := []map[string]any{}
+	result := map[string]any{"fields": fields, "files": files}
+
+	mr := multipart.NewReader(r, boundary)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if part.FileName() == "" {
+			_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			pr, err := charsetReader(params["charset"], part)
+			if err != nil {
+				return result, err
+			}
+			b, err := io.ReadAll(pr)
+			if err != nil {
+				return result, err
+			}
+			fields[part.FormName()] = append(fields[part.FormName()], string(b))
+			continue
+		}
+
+		digest := sha256.New()
+		size, err := io.Copy(digest, part)
+		if err != nil {
+			return result, err
+		}
+		files = append(files, map[string]any{
+			"field":       part.FormName(),
+			"filename":    part.FileName(),
+			"contentType": part.Header.Get("Content-Type"),
+			"size":        size,
+			"sha256":      hex.EncodeToString(digest.Sum(nil)),
+		})
+		result["files"] = files
+	}
+}
 
+func unpackBase64(r io.Reader) (string, error) {
 	s := strings.Builder{}
 	enc := base64.NewEncoder(base64.RawURLEncoding, &s)
-	_, err := io.Copy(enc, body)
+	_, err := io.Copy(enc, r)
+	_ = enc.Close()
 
 	return s.String(), err
 }
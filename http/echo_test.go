@@ -2,10 +2,14 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -204,7 +208,7 @@ func TestUnpackRequest(t *testing.T) {
 				return r, nil
 			},
 			expected: map[string]any{
-				"body":             "AQID",
+				"body":             "AQIDBA",
 				"contentLength":    int64(4),
 				"headers":          map[string]string(nil),
 				"host":             "domain.test",
@@ -246,3 +250,236 @@ func TestUnpackRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoHandlerQueryControl(t *testing.T) {
+	tests := map[string]struct {
+		query          string
+		expectedStatus int
+		expectedHeader http.Header
+		expectedBody   *string
+	}{
+		"no query": {
+			expectedStatus: http.StatusOK,
+			expectedHeader: http.Header{"Content-Type": {"application/json"}},
+		},
+		"status and headers": {
+			query:          "status=503&header=Retry-After:%201&header=X-Echo:a&header=X-Echo:b",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedHeader: http.Header{"Content-Type": {"application/json"}, "Retry-After": {"1"}, "X-Echo": {"a", "b"}},
+		},
+		"delay capped": {
+			query:          "delay=1h",
+			expectedStatus: http.StatusOK,
+			expectedHeader: http.Header{"Content-Type": {"application/json"}},
+		},
+		"delay in milliseconds": {
+			query:          "delay=1&status=201",
+			expectedStatus: http.StatusCreated,
+			expectedHeader: http.Header{"Content-Type": {"application/json"}},
+		},
+		"no content": {
+			query:          "status=204&header=X-Echo:a",
+			expectedStatus: http.StatusNoContent,
+			expectedHeader: http.Header{"X-Echo": {"a"}},
+			expectedBody:   new(string),
+		},
+		"not modified": {
+			query:          "status=304",
+			expectedStatus: http.StatusNotModified,
+			expectedHeader: http.Header{},
+			expectedBody:   new(string),
+		},
+		"invalid status": {
+			query:          "header=X-Echo:a&status=99",
+			expectedStatus: http.StatusBadRequest,
+			expectedHeader: http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "X-Content-Type-Options": {"nosniff"}},
+		},
+		"invalid header": {
+			query:          "header=X-Echo:a&header=novalue",
+			expectedStatus: http.StatusBadRequest,
+			expectedHeader: http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "X-Content-Type-Options": {"nosniff"}},
+		},
+		"content type header": {
+			query:          "header=content-type:text/html",
+			expectedStatus: http.StatusBadRequest,
+			expectedHeader: http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "X-Content-Type-Options": {"nosniff"}},
+		},
+		"content length header": {
+			query:          "header=Content-Length:%2010",
+			expectedStatus: http.StatusBadRequest,
+			expectedHeader: http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "X-Content-Type-Options": {"nosniff"}},
+		},
+		"invalid delay": {
+			query:          "header=X-Echo:a&delay=soon",
+			expectedStatus: http.StatusBadRequest,
+			expectedHeader: http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "X-Content-Type-Options": {"nosniff"}},
+		},
+	}
+
+	logger, _ := testLogger()
+	handler := EchoHandler(logger, WithEchoQueryControl(5*time.Millisecond))
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/echo?"+tc.query, nil))
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedHeader != nil {
+				assert.Equal(t, tc.expectedHeader, rec.Header())
+			}
+			if tc.expectedBody != nil {
+				assert.Equal(t, *tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestEchoHandlerQueryIgnoredByDefault(t *testing.T) {
+	logger, _ := testLogger()
+	rec := httptest.NewRecorder()
+	EchoHandler(logger)(rec, httptest.NewRequest(http.MethodGet, "/echo?status=500&delay=1h", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestEchoHandlerDelayCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	logger, _ := testLogger()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/echo?delay=1h&status=201", nil).WithContext(ctx)
+	EchoHandler(logger, WithEchoQueryControl(time.Hour))(rec, req)
+
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Header())
+}
+
+func TestUnpackRequestBody(t *testing.T) {
+	multipartBody := "--b\r\n" +
+		"Content-Disposition: form-data; name=\"title\"\r\n\r\n" +
+		"hello\r\n" +
+		"--b\r\n" +
+		"Content-Disposition: form-data; name=\"title\"\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n\r\n" +
+		"caf\xe9\r\n" +
+		"--b\r\n" +
+		"Content-Disposition: form-data; name=\"upload\"; filename=\"a.txt\"\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"abc\r\n" +
+		"--b--\r\n"
+
+	tests := map[string]struct {
+		header        http.Header
+		body          []byte
+		expected      any
+		expectedError require.ErrorAssertionFunc
+	}{
+		"json array": {
+			header:        http.Header{"Content-Type": {"application/problem+json"}},
+			body:          []byte(`[1,"a"]`),
+			expected:      []any{float64(1), "a"},
+			expectedError: require.NoError,
+		},
+		"ndjson": {
+			header:        http.Header{"Content-Type": {"application/x-ndjson"}},
+			body:          []byte("{\"a\":1}\n{\"b\":2}\n"),
+			expected:      []any{map[string]any{"a": float64(1)}, map[string]any{"b": float64(2)}},
+			expectedError: require.NoError,
+		},
+		"form": {
+			header:        http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:          []byte("a=1&a=2&b=x+y"),
+			expected:      map[string][]string{"a": {"1", "2"}, "b": {"x y"}},
+			expectedError: require.NoError,
+		},
+		"xml": {
+			header: http.Header{"Content-Type": {"application/atom+xml"}},
+			body:   []byte(`<?xml version="1.0"?><feed xmlns="urn:f" lang="en"><title> Hi </title><entry id="1"/></feed>`),
+			expected: map[string]any{
+				"name":       "feed",
+				"namespace":  "urn:f",
+				"attributes": map[string]string{"xmlns": "urn:f", "lang": "en"},
+				"children": []any{
+					map[string]any{"name": "title", "namespace": "urn:f", "text": "Hi"},
+					map[string]any{"name": "entry", "namespace": "urn:f", "attributes": map[string]string{"id": "1"}},
+				},
+			},
+			expectedError: require.NoError,
+		},
+		"xml declared encoding": {
+			header:        http.Header{"Content-Type": {"text/xml"}},
+			body:          []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>caf\xe9</a>"),
+			expected:      map[string]any{"name": "a", "text": "café"},
+			expectedError: require.NoError,
+		},
+		"text charset": {
+			header:        http.Header{"Content-Type": {"text/plain; charset=windows-1252"}},
+			body:          []byte("caf\xe9 \x80"),
+			expected:      "café €",
+			expectedError: require.NoError,
+		},
+		"unsupported charset": {
+			header:        http.Header{"Content-Type": {"text/plain; charset=unknown-1"}},
+			body:          []byte("abc"),
+			expected:      "YWJj",
+			expectedError: require.Error,
+		},
+		"multipart": {
+			header: http.Header{"Content-Type": {"multipart/form-data; boundary=b"}},
+			body:   []byte(multipartBody),
+			expected: map[string]any{
+				"fields": map[string][]string{"title": {"hello", "café"}},
+				"files": []map[string]any{{
+					"field":       "upload",
+					"filename":    "a.txt",
+					"contentType": "text/plain",
+					"size":        int64(3),
+					"sha256":      "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+				}},
+			},
+			expectedError: require.NoError,
+		},
+		"multipart without boundary": {
+			header:        http.Header{"Content-Type": {"multipart/form-data"}},
+			body:          []byte(multipartBody),
+			expectedError: require.Error,
+		},
+		"gzip json": {
+			header:        http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+			body:          gzipBytes(t, []byte(`{"a":"b"}`)),
+			expected:      map[string]any{"a": "b"},
+			expectedError: require.NoError,
+		},
+		"unsupported content encoding": {
+			header:        http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"compress"}},
+			body:          []byte{0x1, 0x2, 0x3},
+			expected:      "AQID",
+			expectedError: require.Error,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			body := &trackedBody{Reader: bytes.NewReader(tc.body)}
+
+			b, err := UnpackRequestBody(tc.header, body)
+			tc.expectedError(t, err)
+			assert.Equal(t, tc.expected, b)
+			assert.False(t, body.closed)
+		})
+	}
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,285 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHedgeDelay       = 100 * time.Millisecond
+	DefaultHedgeMaxAttempts = 2
+	DefaultHedgeWindow      = 100
+	// DefaultHedgeMinSamples is the number of the latency samples, before the percentile replaces the fixed delay.
+	DefaultHedgeMinSamples = 10
+)
+
+// NewHedgingRoundTripper returns a [http.RoundTripper] that hedges the idempotent requests: when an attempt takes longer than
+// the hedge delay, another attempt is fired in parallel. The first successful response wins, the other attempts are canceled
+// and their responses are drained with [DrainAndCloseResponse]. By default GET and HEAD requests get one hedge after 100ms.
+func NewHedgingRoundTripper(next http.RoundTripper, opts ...HedgeOption) *HedgingRoundTripper {
+	rt := &HedgingRoundTripper{
+		next:        next,
+		delay:       DefaultHedgeDelay,
+		maxAttempts: DefaultHedgeMaxAttempts,
+		methods:     []string{http.MethodGet, http.MethodHead},
+		succeeded: func(resp *http.Response, err error) bool {
+			return err == nil && resp.StatusCode < http.StatusInternalServerError
+		},
+	}
+
+	for _, o := range opts {
+		o(rt)
+	}
+
+	return rt
+}
+
+type HedgeOption func(rt *HedgingRoundTripper)
+
+// WithHedgeDelay sets the fixed delay after which a hedge attempt is fired.
+func WithHedgeDelay(d time.Duration) HedgeOption {
+	return func(rt *HedgingRoundTripper) {
+		rt.delay = max(d, 0)
+	}
+}
+
+// WithHedgePercentile fires the hedge attempts at the given latency percentile (e.g. 0.95) of the last window (default 100)
+// successful attempts. The fixed delay ([WithHedgeDelay]) is used until there are enough samples.
+func WithHedgePercentile(percentile float64, window int) HedgeOption {
+	if window <= 0 {
+		window = DefaultHedgeWindow
+	}
+
+	return func(rt *HedgingRoundTripper) {
+		rt.latencies = &latencyTracker{
+			percentile: min(max(percentile, 0), 1),
+			samples:    make([]time.Duration, 0, max(window, DefaultHedgeMinSamples)),
+		}
+	}
+}
+
+// WithHedgeMaxAttempts sets the maximum number of the parallel attempts, including the first one.
+func WithHedgeMaxAttempts(n int) HedgeOption {
+	return func(rt *HedgingRoundTripper) {
+		rt.maxAttempts = max(n, 1)
+	}
+}
+
+// WithHedgeMethods sets the request methods that are hedged. Requests with an Idempotency-Key header are always hedged.
+func WithHedgeMethods(methods ...string) HedgeOption {
+	return func(rt *HedgingRoundTripper) {
+		rt.methods = methods
+	}
+}
+
+// WithHedgeSuccessCondition replaces the default (no error and status code < 500) condition of a winning response.
+// An attempt that does not succeed fires the next hedge attempt right away.
+func WithHedgeSuccessCondition(c func(resp *http.Response, err error) bool) HedgeOption {
+	return func(rt *HedgingRoundTripper) {
+		rt.succeeded = c
+	}
+}
+
+type HedgingRoundTripper struct {
+	next        http.RoundTripper
+	delay       time.Duration
+	maxAttempts int
+	methods     []string
+	succeeded   func(resp *http.Response, err error) bool
+	latencies   *latencyTracker
+}
+
+type hedgeResult struct {
+	index   int
+	resp    *http.Response
+	err     error
+	cancel  context.CancelFunc
+	latency time.Duration
+}
+
+func (rt *HedgingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.maxAttempts <= 1 || !rt.hedgeable(req) {
+		return rt.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	results := make(chan hedgeResult, rt.maxAttempts)
+	cancels := make([]context.CancelFunc, 0, rt.maxAttempts)
+
+	launch := func() error {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		r := req.Clone(attemptCtx)
+
+		if len(cancels) > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return errors.Join(ErrHedgeBodyReplay, err)
+			}
+			r.Body = body
+		}
+
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			start := time.Now()
+			resp, err := rt.next.RoundTrip(r) //nolint:bodyclose // the body is returned or drained by the caller.
+			results <- hedgeResult{index: index, resp: resp, err: err, cancel: cancel, latency: time.Since(start)}
+		}()
+
+		return nil
+	}
+
+	delay := rt.hedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	if err := launch(); err != nil {
+		return nil, err
+	}
+	pending := 1
+
+	var last *hedgeResult
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+
+			if rt.succeeded(res.resp, res.err) {
+				rt.record(res.latency)
+				discardHedges(results, pending, cancels, res.index, last)
+				return withCancel(res.resp, res.err, res.cancel)
+			}
+
+			discardHedge(last)
+			last = &res
+
+			// a failed attempt fires the next one right away.
+			if len(cancels) < rt.maxAttempts && ctx.Err() == nil && launch() == nil {
+				pending++
+				timer.Reset(delay)
+			}
+
+		case <-timer.C:
+			if len(cancels) < rt.maxAttempts && launch() == nil {
+				pending++
+				timer.Reset(delay)
+			}
+
+		case <-ctx.Done():
+			discardHedges(results, pending, cancels, -1, last)
+			return nil, ctx.Err()
+		}
+	}
+
+	// every attempt failed, the last one is returned.
+	return withCancel(last.resp, last.err, last.cancel)
+}
+
+// hedgeable reports whether the request can be hedged: it has to be idempotent and its body (if any) replayable.
+func (rt *HedgingRoundTripper) hedgeable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return true
+	}
+
+	return slices.Contains(rt.methods, req.Method)
+}
+
+func (rt *HedgingRoundTripper) hedgeDelay() time.Duration {
+	if rt.latencies == nil {
+		return rt.delay
+	}
+
+	if d, exists := rt.latencies.value(); exists {
+		return d
+	}
+
+	return rt.delay
+}
+
+func (rt *HedgingRoundTripper) record(latency time.Duration) {
+	if rt.latencies != nil {
+		rt.latencies.add(latency)
+	}
+}
+
+// discardHedges cancels every attempt except the winner (whose cancel is tied to its response body), drains the last failed
+// response and, in the background, the responses of the pending attempts.
+func discardHedges(results <-chan hedgeResult, pending int, cancels []context.CancelFunc, winner int, last *hedgeResult) {
+	for i, cancel := range cancels {
+		if i != winner {
+			cancel()
+		}
+	}
+
+	discardHedge(last)
+
+	if pending == 0 {
+		return
+	}
+
+	go func() {
+		for range pending {
+			res := <-results
+			discardHedge(&res)
+		}
+	}()
+}
+
+func discardHedge(res *hedgeResult) {
+	if res == nil {
+		return
+	}
+
+	_ = DrainAndCloseResponse(res.resp)
+	res.cancel()
+}
+
+var ErrHedgeBodyReplay = errors.New("hedge: failed to replay the request body")
+
+// latencyTracker keeps a window of the latest latencies and computes their percentile.
+type latencyTracker struct {
+	percentile float64
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (lt *latencyTracker) add(d time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if len(lt.samples) < cap(lt.samples) {
+		lt.samples = append(lt.samples, d)
+		return
+	}
+
+	lt.samples[lt.next] = d
+	lt.next = (lt.next + 1) % len(lt.samples)
+}
+
+func (lt *latencyTracker) value() (time.Duration, bool) {
+	lt.mu.Lock()
+	if len(lt.samples) < DefaultHedgeMinSamples {
+		lt.mu.Unlock()
+		return 0, false
+	}
+	sorted := slices.Clone(lt.samples)
+	lt.mu.Unlock()
+
+	slices.Sort(sorted)
+
+	i := int(lt.percentile * float64(len(sorted)-1))
+
+	return sorted[i], true
+}
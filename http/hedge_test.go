@@ -0,0 +1,200 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signalBody signals its close.
+type signalBody struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (b *signalBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestHedgingRoundTripper(t *testing.T) {
+	t.Run("fast first attempt", func(t *testing.T) {
+		var calls atomic.Int32
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("first"))}, nil
+		})
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(time.Second))
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, "first", string(body))
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("hedge wins", func(t *testing.T) {
+		var calls atomic.Int32
+		loserReader := strings.NewReader("slow")
+		loserBody := &signalBody{Reader: loserReader, closed: make(chan struct{})}
+
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if calls.Add(1) == 1 {
+				<-req.Context().Done()
+				return &http.Response{StatusCode: http.StatusOK, Body: loserBody}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("hedge"))}, nil
+		})
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(10*time.Millisecond))
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, "hedge", string(body))
+		assert.Equal(t, int32(2), calls.Load())
+
+		select {
+		case <-loserBody.closed:
+			assert.Equal(t, 0, loserReader.Len())
+		case <-time.After(time.Second):
+			assert.Fail(t, "the losing response was not drained and closed")
+		}
+	})
+
+	t.Run("failed attempt fires the hedge right away", func(t *testing.T) {
+		var calls atomic.Int32
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if calls.Add(1) == 1 {
+				return nil, errTransport
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(time.Hour))
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("every attempt fails", func(t *testing.T) {
+		var calls atomic.Int32
+		bodies := make([]*trackedBody, 0, 3)
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			b := &trackedBody{Reader: strings.NewReader("error")}
+			bodies = append(bodies, b)
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: b}, nil
+		})
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(time.Hour), WithHedgeMaxAttempts(3))
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+		require.Len(t, bodies, 3)
+		assert.True(t, bodies[0].drained && bodies[0].closed)
+		assert.True(t, bodies[1].drained && bodies[1].closed)
+		assert.False(t, bodies[2].drained)
+	})
+
+	t.Run("not hedgeable", func(t *testing.T) {
+		var calls atomic.Int32
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(time.Millisecond))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://a.test/", strings.NewReader("body"))
+		require.NoError(t, err)
+
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("idempotency key replays the body", func(t *testing.T) {
+		var (
+			calls  atomic.Int32
+			mu     sync.Mutex
+			bodies []string
+		)
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			b, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			mu.Unlock()
+			if calls.Add(1) == 1 {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(5*time.Millisecond))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://a.test/", bytes.NewReader([]byte("payload")))
+		require.NoError(t, err)
+		req.Header.Set("Idempotency-Key", "abc")
+
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		rt := NewHedgingRoundTripper(next, WithHedgeDelay(5*time.Millisecond))
+		_, err := rt.RoundTrip(getRequest(t, "http://a.test/").WithContext(ctx)) //nolint:bodyclose // no response.
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestHedgingRoundTripperPercentile(t *testing.T) {
+	rt := NewHedgingRoundTripper(nil, WithHedgeDelay(time.Second), WithHedgePercentile(0.9, 20))
+
+	// not enough samples.
+	for i := range DefaultHedgeMinSamples - 1 {
+		rt.record(time.Duration(i+1) * time.Millisecond)
+	}
+	assert.Equal(t, time.Second, rt.hedgeDelay())
+
+	rt.record(10 * time.Millisecond)
+	assert.Equal(t, 9*time.Millisecond, rt.hedgeDelay())
+
+	// the window keeps the latest 20 samples.
+	for range 20 {
+		rt.record(50 * time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, rt.hedgeDelay())
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 )
@@ -33,3 +35,99 @@ func DrainAndCloseBody(body io.ReadCloser) error {
 
 	return errors.Join(discardErr, body.Close())
 }
+
+// DrainOutcome reports how a bounded drain ([DrainAndCloseBodyContext]) disposed a body.
+type DrainOutcome int
+
+const (
+	// Drained means that the body was consumed til the end and closed, so the connection can be reused.
+	Drained DrainOutcome = iota
+	// DrainLimitExceeded means that the body was longer than the cap and it was closed without being fully drained.
+	DrainLimitExceeded
+	// DrainCanceled means that the context was done (or its deadline exceeded) and the body was closed without being fully drained.
+	DrainCanceled
+	// DrainFailed means that reading the body failed and it was closed without being fully drained.
+	DrainFailed
+)
+
+func (o DrainOutcome) String() string {
+	switch o {
+	case Drained:
+		return "drained"
+	case DrainLimitExceeded:
+		return "limit exceeded"
+	case DrainCanceled:
+		return "canceled"
+	case DrainFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("DrainOutcome(%d)", int(o))
+	}
+}
+
+// DrainAndCloseBodyN drains up to maxBytes of the body and closes it. A body longer than maxBytes is just closed,
+// sacrificing the connection reuse. A maxBytes <= 0 means no cap.
+func DrainAndCloseBodyN(body io.ReadCloser, maxBytes int64) (DrainOutcome, error) {
+	return DrainAndCloseBodyContext(context.Background(), body, maxBytes)
+}
+
+// DrainAndCloseBodyContext drains up to maxBytes (<= 0 means no cap) of the body and closes it. When the cap is hit, or the
+// context is done before the body is drained, the body is just closed, sacrificing the connection reuse. On context done
+// the body is closed concurrently to unblock the pending read, as the response bodies of [http.Client] support.
+// The returned outcome reports which path was taken.
+func DrainAndCloseBodyContext(ctx context.Context, body io.ReadCloser, maxBytes int64) (DrainOutcome, error) {
+	if body == nil || body == http.NoBody {
+		return Drained, nil
+	}
+
+	if ctx.Err() != nil {
+		return DrainCanceled, body.Close()
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = body.Close() })
+
+	outcome, drainErr := drain(body, maxBytes)
+
+	if !stop() {
+		// the context was done and the body is (being) closed by the AfterFunc.
+		if drainErr == nil {
+			return outcome, nil
+		}
+		return DrainCanceled, nil
+	}
+
+	closeErr := body.Close()
+	if drainErr != nil {
+		return outcome, errors.Join(drainErr, closeErr)
+	}
+
+	return outcome, closeErr
+}
+
+// DrainAndCloseResponseContext is [DrainAndCloseBodyContext] for the response body.
+func DrainAndCloseResponseContext(ctx context.Context, r *http.Response, maxBytes int64) (DrainOutcome, error) {
+	if r == nil {
+		return Drained, nil
+	}
+
+	return DrainAndCloseBodyContext(ctx, r.Body, maxBytes)
+}
+
+func drain(body io.Reader, maxBytes int64) (DrainOutcome, error) {
+	if maxBytes <= 0 {
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return DrainFailed, err
+		}
+		return Drained, nil
+	}
+
+	_, err := io.CopyN(io.Discard, body, maxBytes+1)
+	switch {
+	case errors.Is(err, io.EOF):
+		return Drained, nil
+	case err != nil:
+		return DrainFailed, err
+	default:
+		return DrainLimitExceeded, nil
+	}
+}
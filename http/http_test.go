@@ -2,10 +2,12 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ifnotnil/x/http/internal/testingx"
 	"github.com/stretchr/testify/assert"
@@ -65,6 +67,97 @@ func TestDrain(t *testing.T) {
 	}
 }
 
+func TestDrainAndCloseBodyContext(t *testing.T) {
+	tests := map[string]struct {
+		Body            string
+		MaxBytes        int64
+		CloseErr        error
+		ExpectedOutcome DrainOutcome
+		ExpectedErr     string
+	}{
+		"no cap":              {Body: "abcd1234", MaxBytes: 0, ExpectedOutcome: Drained},
+		"under the cap":       {Body: "abcd1234", MaxBytes: 100, ExpectedOutcome: Drained},
+		"exactly the cap":     {Body: "abcd1234", MaxBytes: 8, ExpectedOutcome: Drained},
+		"over the cap":        {Body: "abcd1234", MaxBytes: 4, ExpectedOutcome: DrainLimitExceeded},
+		"close error":         {Body: "abcd1234", MaxBytes: 4, CloseErr: errors.New("close error"), ExpectedOutcome: DrainLimitExceeded, ExpectedErr: "close error"},
+		"close error drained": {Body: "abcd", CloseErr: errors.New("close error"), ExpectedOutcome: Drained, ExpectedErr: "close error"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := newIOReadCloserMock(t, tc.CloseErr)
+			m.buf.WriteString(tc.Body)
+
+			outcome, err := DrainAndCloseBodyN(m, tc.MaxBytes)
+			assert.Equal(t, tc.ExpectedOutcome, outcome)
+			if tc.ExpectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.ExpectedErr)
+			}
+		})
+	}
+
+	t.Run("nils", func(t *testing.T) {
+		outcome, err := DrainAndCloseBodyN(nil, 10)
+		require.NoError(t, err)
+		assert.Equal(t, Drained, outcome)
+
+		outcome, err = DrainAndCloseResponseContext(context.Background(), nil, 10)
+		require.NoError(t, err)
+		assert.Equal(t, Drained, outcome)
+
+		outcome, err = DrainAndCloseResponseContext(context.Background(), &http.Response{Body: http.NoBody}, 10)
+		require.NoError(t, err)
+		assert.Equal(t, Drained, outcome)
+	})
+
+	t.Run("slow body and deadline", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = pw.Write([]byte("abc"))
+			// never finishes.
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		outcome, err := DrainAndCloseBodyContext(ctx, pr, 0)
+		require.NoError(t, err)
+		assert.Equal(t, DrainCanceled, outcome)
+
+		// the body is closed.
+		_, err = pw.Write([]byte("x"))
+		require.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+
+	t.Run("context already done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := newIOReadCloserMock(t, nil)
+		m.buf.WriteString("abcd")
+
+		outcome, err := DrainAndCloseBodyContext(ctx, m, 0)
+		require.NoError(t, err)
+		assert.Equal(t, DrainCanceled, outcome)
+		assert.Equal(t, 4, m.buf.Len())
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		readErr := errors.New("read error")
+		pr, pw := io.Pipe()
+		_ = pw.CloseWithError(readErr)
+
+		outcome, err := DrainAndCloseBodyN(pr, 10)
+		require.ErrorIs(t, err, readErr)
+		assert.Equal(t, DrainFailed, outcome)
+	})
+
+	assert.Equal(t, "limit exceeded", DrainLimitExceeded.String())
+	assert.Equal(t, "DrainOutcome(9)", DrainOutcome(9).String())
+}
+
 type ioReadCloserMock struct {
 	mock.Mock
 	buf bytes.Buffer
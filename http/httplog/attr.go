@@ -1,12 +1,17 @@
 package httplog
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type HTTPSLogAttrsConverter struct {
@@ -31,18 +36,99 @@ func (a HTTPSLogAttrsConverter) Headers(key string, h http.Header) slog.Attr {
 }
 
 func (a HTTPSLogAttrsConverter) URL(u *url.URL) slog.Attr {
-	s := make([]slog.Attr, 0, 6)
+	s := make([]slog.Attr, 0, 8)
+
+	userInfo := ""
+	if a.logPolicy.LogURLUser && u.User != nil {
+		s = append(s, attrURLUser(u.User))
+		userInfo = url.User(u.User.Username()).String()
+		if _, hasPassword := u.User.Password(); hasPassword {
+			userInfo += ":***"
+		}
+		userInfo += "@"
+	}
 
 	s = append(s, slog.String("scheme", u.Scheme))
 	s = append(s, slog.String("opaque", u.Opaque))
 	s = append(s, slog.String("host", u.Host))
 	s = append(s, slog.String("path", u.Path))
+
+	full := fmt.Sprintf("%s://%s%s%s", u.Scheme, userInfo, u.Host, u.Path)
+	if a.logPolicy.LogQuery && u.RawQuery != "" {
+		query, rawQuery := a.query(u.RawQuery)
+		if len(query) > 0 {
+			s = append(s, slog.Attr{Key: "query", Value: slog.GroupValue(query...)})
+			full += "?" + rawQuery
+		}
+	}
+
 	s = append(s, slog.String("fragment", u.Fragment))
-	s = append(s, slog.String("full", fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path)))
+	s = append(s, slog.String("full", full))
 
 	return slog.Attr{Key: "url", Value: slog.GroupValue(s...)}
 }
 
+// query returns the attrs of the logged query parameters (in the order of their first appearance) and the raw query
+// with the omitted parameters removed and the masked values replaced.
+func (a HTTPSLogAttrsConverter) query(rawQuery string) ([]slog.Attr, string) {
+	values, _ := url.ParseQuery(rawQuery)
+
+	attrs := make([]slog.Attr, 0, len(values))
+	pairs := make([]string, 0, len(values))
+	logged := make(map[string]bool, len(values))
+
+	for pair := range strings.SplitSeq(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		// the pairs that url.ParseQuery rejects are never logged, as their key or value is ambiguous.
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, keyErr := url.QueryUnescape(rawKey)
+		_, valueErr := url.QueryUnescape(rawValue)
+		if keyErr != nil || valueErr != nil || strings.Contains(pair, ";") {
+			continue
+		}
+
+		vals := values[key]
+		if a.logPolicy.ShouldOmitQueryParam(key, vals) {
+			continue
+		}
+
+		masked := a.logPolicy.ShouldMaskQueryParam(key, vals)
+		if masked {
+			pairs = append(pairs, rawKey+"=***")
+		} else {
+			pairs = append(pairs, pair)
+		}
+
+		if logged[key] {
+			continue
+		}
+		logged[key] = true
+
+		switch {
+		case masked:
+			attrs = append(attrs, slog.String(key, "***"))
+		case len(vals) == 1:
+			attrs = append(attrs, slog.String(key, vals[0]))
+		default:
+			attrs = append(attrs, slog.Any(key, vals))
+		}
+	}
+
+	return attrs, strings.Join(pairs, "&")
+}
+
+func attrURLUser(u *url.Userinfo) slog.Attr {
+	s := []slog.Attr{slog.String("username", u.Username())}
+	if _, hasPassword := u.Password(); hasPassword {
+		s = append(s, slog.String("password", "***"))
+	}
+
+	return slog.Attr{Key: "user", Value: slog.GroupValue(s...)}
+}
+
 func (a HTTPSLogAttrsConverter) HTTPRequest(r *http.Request) slog.Attr {
 	s := a.AttrsHTTPRequestExcludeBody(r)
 
@@ -68,12 +154,12 @@ func (a HTTPSLogAttrsConverter) AttrsHTTPRequestExcludeBody(r *http.Request) []s
 
 	// Form - for inbound: This field is only available after ParseForm is called.
 	if len(r.Form) > 0 {
-		s = append(s, attrURLValues("form", r.Form))
+		s = append(s, a.urlValues("form", r.Form))
 	}
 
 	// PostForm - for inbound: This field is only available after ParseForm is called.
 	if len(r.PostForm) > 0 {
-		s = append(s, attrURLValues("postForm", r.PostForm))
+		s = append(s, a.urlValues("postForm", r.PostForm))
 	}
 
 	// MultipartForm - for inbound: This field is only available after ParseMultipartForm
@@ -84,7 +170,7 @@ func (a HTTPSLogAttrsConverter) AttrsHTTPRequestExcludeBody(r *http.Request) []s
 	}
 
 	s = append(s, slog.String("remoteAddr", r.RemoteAddr))
-	s = append(s, slog.String("requestUri", r.RequestURI))
+	s = append(s, slog.String("requestUri", a.requestURI(r.RequestURI)))
 
 	// TLS *tls.ConnectionState
 	if r.TLS != nil {
@@ -107,11 +193,11 @@ func (a HTTPSLogAttrsConverter) AttrsHTTPRequestBodyDrain(r *http.Request) []slo
 	case !a.logPolicy.ShouldLogRequestBody(r):
 		s = append(s, slog.String("bodyLogNote", "body is not logable"))
 	default:
-		payloadBytes, err := drainRequestBody(r)
+		payloadBytes, size, err := drainRequestBody(r, a.logPolicy.MaxRequestBodyLogBytes)
 		if err != nil {
 			s = append(s, slog.String("bodyLogNote", "log body error - "+err.Error()))
 		}
-		s = append(s, attrBody(payloadBytes))
+		s = append(s, a.attrBody(r.Header.Get("Content-Type"), payloadBytes, size))
 	}
 
 	return s
@@ -160,11 +246,11 @@ func (a HTTPSLogAttrsConverter) AttrsHTTPResponseDrainBody(r *http.Response) []s
 	case !a.logPolicy.ShouldLogResponseBody(r):
 		s = append(s, slog.String("bodyLogNote", "body is not logable"))
 	default:
-		payloadBytes, err := drainResponseBody(r)
+		payloadBytes, size, err := drainResponseBody(r, a.logPolicy.MaxResponseBodyLogBytes)
 		if err != nil {
 			s = append(s, slog.String("bodyLogNote", "log body error - %s"+err.Error()))
 		}
-		s = append(s, attrBody(payloadBytes))
+		s = append(s, a.attrBody(r.Header.Get("Content-Type"), payloadBytes, size))
 	}
 
 	return s
@@ -179,13 +265,23 @@ type BytesBuffer interface {
 }
 
 func (a HTTPSLogAttrsConverter) HTTPResponseWriter(headers http.Header, statusCode int, body []byte) slog.Attr {
+	return a.httpResponseWriter(headers, statusCode, body, int64(len(body)))
+}
+
+// HTTPResponseWriterWrapper is like HTTPResponseWriter for the (capped) body buffered by the wrapper, and the size of
+// the whole written body.
+func (a HTTPSLogAttrsConverter) HTTPResponseWriterWrapper(w ResponseWriterWrapper) slog.Attr {
+	return a.httpResponseWriter(w.Header(), w.Status(), w.Buffer().Bytes(), int64(w.BytesWritten()))
+}
+
+func (a HTTPSLogAttrsConverter) httpResponseWriter(headers http.Header, statusCode int, body []byte, size int64) slog.Attr {
 	s := make([]slog.Attr, 0, 3)
 
 	s = append(s, a.Headers("headers", headers))
 	s = append(s, attrStatusCode(statusCode))
 
 	if a.logPolicy.ShouldLogResponseWriterBody(headers, statusCode, body) {
-		s = append(s, attrBody(body))
+		s = append(s, a.attrBody(headers.Get("Content-Type"), body, size))
 	}
 
 	return slog.Attr{Key: "response", Value: slog.GroupValue(s...)}
@@ -202,14 +298,41 @@ func attrStatusCode(statusCode int) slog.Attr {
 	return slog.Group("status", slog.Int("code", statusCode), slog.String("name", http.StatusText(statusCode)))
 }
 
-func attrURLValues(key string, u url.Values) slog.Attr {
+// requestURI returns the request uri with its query filtered and masked like the url one, when the query parameters
+// are configured in the policy.
+func (a HTTPSLogAttrsConverter) requestURI(requestURI string) string {
+	if !a.logPolicy.filtersQuery() {
+		return requestURI
+	}
+
+	path, rawQuery, hasQuery := strings.Cut(requestURI, "?")
+	if !hasQuery || !a.logPolicy.LogQuery {
+		return path
+	}
+
+	if _, rawQuery = a.query(rawQuery); rawQuery == "" {
+		return path
+	}
+
+	return path + "?" + rawQuery
+}
+
+// urlValues returns the attr of the form values, that are omitted and masked by the query parameters policy, since they
+// include the query ones.
+func (a HTTPSLogAttrsConverter) urlValues(key string, u url.Values) slog.Attr {
 	if len(u) == 0 {
 		return slog.Attr{}
 	}
 
 	attrs := make([]slog.Attr, 0, len(u))
-	for k := range u {
-		attrs = append(attrs, slog.String(k, u.Get(k)))
+	for k, v := range u {
+		switch {
+		case a.logPolicy.ShouldOmitQueryParam(k, v):
+		case a.logPolicy.ShouldMaskQueryParam(k, v):
+			attrs = append(attrs, slog.String(k, "***"))
+		default:
+			attrs = append(attrs, slog.String(k, u.Get(k)))
+		}
 	}
 
 	return slog.Attr{
@@ -226,12 +349,83 @@ func attrTLS(key string, tls *tls.ConnectionState) slog.Attr {
 	)
 }
 
-func attrBody(body []byte) slog.Attr {
-	return slog.Group(
-		"body",
-		slog.Int("size", len(body)),
-		slog.String("value", sanitizeJSONBytesToLog(body)),
-	)
+// attrBody logs the (redacted) body bytes and the size of the whole body. When the body bytes are less than the size
+// (or the size is unknown, -1), the body is marked as truncated.
+func (a HTTPSLogAttrsConverter) attrBody(contentType string, body []byte, size int64) slog.Attr {
+	s := make([]slog.Attr, 0, 3)
+
+	if size >= 0 {
+		s = append(s, slog.Int64("size", size))
+	}
+
+	truncated := size != int64(len(body))
+	body = a.logPolicy.RedactBody(contentType, body)
+
+	value, structured := slog.Value{}, false
+	if !truncated && a.logPolicy.structuredBody(contentType, len(body)) {
+		value, structured = jsonBodyValue(a.logPolicy.BodyFormat, body)
+	}
+	if !structured {
+		value = slog.StringValue(sanitizeJSONBytesToLog(body))
+	}
+
+	s = append(s, slog.Attr{Key: "value", Value: value})
+	if truncated {
+		s = append(s, slog.Bool("truncated", true))
+	}
+
+	return slog.Attr{Key: "body", Value: slog.GroupValue(s...)}
+}
+
+// jsonBodyValue converts the json body into a slog value of the format. It reports false when the body is not valid json.
+func jsonBodyValue(format BodyFormat, body []byte) (slog.Value, bool) {
+	if format == BodyAsRawJSON {
+		if !json.Valid(body) {
+			return slog.Value{}, false
+		}
+
+		return slog.AnyValue(json.RawMessage(body)), true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return slog.Value{}, false
+	}
+
+	return jsonSlogValue(v), true
+}
+
+func jsonSlogValue(v any) slog.Value {
+	switch t := v.(type) {
+	case map[string]any:
+		// the handlers drop the empty groups.
+		if len(t) == 0 {
+			return slog.AnyValue(json.RawMessage(`{}`))
+		}
+
+		attrs := make([]slog.Attr, 0, len(t))
+		for _, k := range slices.Sorted(maps.Keys(t)) {
+			attrs = append(attrs, slog.Attr{Key: k, Value: jsonSlogValue(t[k])})
+		}
+		return slog.GroupValue(attrs...)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return slog.Int64Value(i)
+		}
+		if f, err := t.Float64(); err == nil {
+			return slog.Float64Value(f)
+		}
+		return slog.StringValue(t.String())
+	case string:
+		return slog.StringValue(t)
+	case bool:
+		return slog.BoolValue(t)
+	default: // arrays and null.
+		return slog.AnyValue(t)
+	}
 }
 
 func sanitizeJSONBytesToLog(b []byte) string {
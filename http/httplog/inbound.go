@@ -23,7 +23,7 @@ func (il *HTTPLogger) handlerDrain(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestAttr := il.attrConverter.HTTPRequest(r)
 
-		wrapResponseWriter := NewResponseWriterWrapper(w)
+		wrapResponseWriter := newResponseWriterWrapper(w, il.logPolicy.MaxResponseBodyLogBytes)
 
 		startTime := time.Now()
 
@@ -35,7 +35,7 @@ func (il *HTTPLogger) handlerDrain(next http.Handler) http.Handler {
 
 		attrs = append(attrs, slog.Duration("duration", time.Since(startTime)))
 
-		responseAttr := il.attrConverter.HTTPResponseWriter(wrapResponseWriter.Header(), wrapResponseWriter.Status(), wrapResponseWriter.Buffer().Bytes())
+		responseAttr := il.attrConverter.HTTPResponseWriterWrapper(wrapResponseWriter)
 
 		attrs = append(attrs, requestAttr)
 		attrs = append(attrs, responseAttr)
@@ -48,17 +48,17 @@ func (il *HTTPLogger) handlerTee(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqAttrs := il.attrConverter.AttrsHTTPRequestExcludeBody(r)
 
-		r.Body = NewTeeReadCloserPooled(r.Body, il.pool, func(readErr, closeErr error, buf *bytes.Buffer) {
+		r.Body = newTeeReadCloserPooled(r.Body, il.pool, il.logPolicy.MaxRequestBodyLogBytes, func(readErr, closeErr error, buf *bytes.Buffer, size int64) {
 			if readErr != nil {
 				reqAttrs = append(reqAttrs, attrError("readError", readErr))
 			}
 			if closeErr != nil {
 				reqAttrs = append(reqAttrs, attrError("closeError", closeErr))
 			}
-			reqAttrs = append(reqAttrs, attrBody(buf.Bytes()))
+			reqAttrs = append(reqAttrs, il.attrConverter.attrBody(r.Header.Get("Content-Type"), buf.Bytes(), size))
 		})
 
-		wrapResponseWriter := NewResponseWriterWrapper(w)
+		wrapResponseWriter := newResponseWriterWrapper(w, il.logPolicy.MaxResponseBodyLogBytes)
 
 		startTime := time.Now()
 
@@ -71,7 +71,7 @@ func (il *HTTPLogger) handlerTee(next http.Handler) http.Handler {
 		attrs = append(attrs, il.attrConverter.GroupAttrsAsHTTPRequest(reqAttrs))
 		attrs = append(
 			attrs,
-			il.attrConverter.HTTPResponseWriter(wrapResponseWriter.Header(), wrapResponseWriter.Status(), wrapResponseWriter.Buffer().Bytes()),
+			il.attrConverter.HTTPResponseWriterWrapper(wrapResponseWriter),
 		)
 
 		il.logInbound(r.Context(), attrs)
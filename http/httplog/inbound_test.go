@@ -170,7 +170,57 @@ func TestInbound(t *testing.T) {
 		},
 	}
 
+	cappedPolicy := LogPolicy{MaxRequestBodyLogBytes: 5, MaxResponseBodyLogBytes: 5}
+	cappedLogs := []map[string]any{
+		{
+			"level": "INFO",
+			"msg":   "http inbound",
+			"request": map[string]any{
+				"body":          map[string]any{"size": float64(14), "value": `\"requ`, "truncated": true},
+				"contentLength": float64(14),
+				"headers":       map[string]any{"Accept-Encoding": "gzip", "Content-Length": "14", "Content-Type": "application/json", "User-Agent": "Go-http-client/1.1"},
+				"method":        "GET",
+				"proto":         "HTTP/1.1",
+				"requestUri":    "/",
+				"url":           map[string]any{"fragment": "", "full": ":///", "host": "", "opaque": "", "path": "/", "scheme": ""},
+			},
+			"response": map[string]any{
+				"body":    map[string]any{"size": float64(15), "value": `\"resp`, "truncated": true},
+				"headers": map[string]any{"Content-Type": "application/json"},
+				"status":  map[string]any{"code": float64(200), "name": "OK"},
+			},
+		},
+	}
+
 	tests := map[string]inboundTestCase{
+		"capped Drain": {
+			initHTTPLogger: func(logger *slog.Logger) *HTTPLogger {
+				return NewHTTPLogger(
+					WithLogger(logger),
+					WithLogInLevel(slog.LevelInfo),
+					WithMode(Drain),
+					WithLogPolicy(cappedPolicy),
+				)
+			},
+			srvHandler:     simpleTc.srvHandler,
+			requestFn:      simpleTc.requestFn,
+			assertResponse: simpleTc.assertResponse,
+			expectedLogs:   cappedLogs,
+		},
+		"capped Tee": {
+			initHTTPLogger: func(logger *slog.Logger) *HTTPLogger {
+				return NewHTTPLogger(
+					WithLogger(logger),
+					WithLogInLevel(slog.LevelInfo),
+					WithMode(Tee),
+					WithLogPolicy(cappedPolicy),
+				)
+			},
+			srvHandler:     simpleTc.srvHandler,
+			requestFn:      simpleTc.requestFn,
+			assertResponse: simpleTc.assertResponse,
+			expectedLogs:   cappedLogs,
+		},
 		"simple Drain": {
 			initHTTPLogger: func(logger *slog.Logger) *HTTPLogger {
 				return NewHTTPLogger(
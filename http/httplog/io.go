@@ -46,47 +46,57 @@ func (b *BytesBufferPool) Put(buf *bytes.Buffer) {
 
 type TeeCallBack func(readErr, closeErr error, buf *bytes.Buffer)
 
+// teeCallBack additionally receives the number of bytes that passed through the tee, which exceeds the buffer length
+// when the buffering is capped.
+type teeCallBack func(readErr, closeErr error, buf *bytes.Buffer, size int64)
+
 func NewTeeReadCloserPooled(r io.Reader, pool *BytesBufferPool, cb TeeCallBack) io.ReadCloser {
-	teeBuffer := pool.Get()
+	return newTeeReadCloserPooled(r, pool, 0, func(readErr, closeErr error, buf *bytes.Buffer, _ int64) {
+		cb(readErr, closeErr, buf)
+	})
+}
 
+// newTeeReadCloserPooled buffers up to maxBytes (no limit when <= 0) of the stream, while the whole stream passes through.
+func newTeeReadCloserPooled(r io.Reader, pool *BytesBufferPool, maxBytes int64, cb teeCallBack) io.ReadCloser {
 	t := &teeReadCloser{
-		inner: r,
-		buf:   teeBuffer,
-		cb: func(readErr, closeErr error, buf *bytes.Buffer) {
-			cb(readErr, closeErr, buf)
+		inner:    r,
+		buf:      pool.Get(),
+		maxBytes: maxBytes,
+		cb: func(readErr, closeErr error, buf *bytes.Buffer, size int64) {
+			cb(readErr, closeErr, buf, size)
 			pool.Put(buf)
 		},
 	}
 
-	if asWriteTo, isWriteTo := r.(io.WriterTo); isWriteTo {
-		writeTo := &teeWriteTo{
-			teeReader: t,
-			from:      asWriteTo,
-		}
-
-		return &teeReadCloserAndWriteTo{teeReadCloser: t, teeWriteTo: writeTo}
-	}
-
-	return &teeReadCloserAndWriteTo{teeReadCloser: t}
+	return t.withWriteTo()
 }
 
 func NewTeeReadCloser(r io.Reader, teeBuffer *bytes.Buffer, cb TeeCallBack) io.ReadCloser {
 	t := &teeReadCloser{
 		inner: r,
 		buf:   teeBuffer,
-		cb:    cb,
 	}
 
-	if asWriteTo, isWriteTo := r.(io.WriterTo); isWriteTo {
+	if cb != nil {
+		t.cb = func(readErr, closeErr error, buf *bytes.Buffer, _ int64) {
+			cb(readErr, closeErr, buf)
+		}
+	}
+
+	return t.withWriteTo()
+}
+
+func (r *teeReadCloser) withWriteTo() io.ReadCloser {
+	if asWriteTo, isWriteTo := r.inner.(io.WriterTo); isWriteTo {
 		writeTo := &teeWriteTo{
-			teeReader: t,
+			teeReader: r,
 			from:      asWriteTo,
 		}
 
-		return &teeReadCloserAndWriteTo{teeReadCloser: t, teeWriteTo: writeTo}
+		return &teeReadCloserAndWriteTo{teeReadCloser: r, teeWriteTo: writeTo}
 	}
 
-	return &teeReadCloserAndWriteTo{teeReadCloser: t}
+	return &teeReadCloserAndWriteTo{teeReadCloser: r}
 }
 
 // ### io.ReadCloser.
@@ -97,7 +107,9 @@ type teeReadCloser struct {
 	readErr  error
 	closeErr error
 	buf      *bytes.Buffer
-	cb       TeeCallBack
+	maxBytes int64
+	size     int64
+	cb       teeCallBack
 	cbOnce   sync.Once
 }
 
@@ -111,7 +123,11 @@ func (r *teeReadCloser) Read(p []byte) (n int, err error) {
 	}
 
 	if n > 0 {
-		if bufN, bufErr := r.buf.Write(p[:n]); bufErr != nil {
+		r.size += int64(n)
+	}
+
+	if w := r.bufferable(n); w > 0 {
+		if bufN, bufErr := r.buf.Write(p[:w]); bufErr != nil {
 			_ = bufN // TODO (or not): bufN != n -> error
 			if !isEOF {
 				return n, errors.Join(err, bufErr)
@@ -124,6 +140,15 @@ func (r *teeReadCloser) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
+// bufferable returns how many of the n read bytes fit in the buffer without exceeding maxBytes.
+func (r *teeReadCloser) bufferable(n int) int {
+	if r.maxBytes <= 0 {
+		return n
+	}
+
+	return int(max(min(int64(n), r.maxBytes-int64(r.buf.Len())), 0))
+}
+
 func (r *teeReadCloser) Buffer() *bytes.Buffer { return r.buf }
 
 func (r *teeReadCloser) Close() error {
@@ -139,7 +164,7 @@ func (r *teeReadCloser) Close() error {
 func (r *teeReadCloser) doCB() {
 	if r.cb != nil {
 		r.cbOnce.Do(func() {
-			r.cb(r.readErr, r.closeErr, r.buf)
+			r.cb(r.readErr, r.closeErr, r.buf, r.size)
 		})
 	}
 }
@@ -173,47 +198,60 @@ type teeReadCloserAndWriteTo struct {
 // Drain functionality
 //
 
-func drainRequestBody(req *http.Request) ([]byte, error) {
+func drainRequestBody(req *http.Request, maxBytes int64) ([]byte, int64, error) {
 	if req.GetBody != nil {
 		body, err := req.GetBody()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
-		return io.ReadAll(body)
-	}
+		logged, size, rest, err := drainBody(body, maxBytes, req.ContentLength)
+		_ = rest.Close()
 
-	buf := &bytes.Buffer{}
+		return logged, size, err
+	}
 
-	defer func() {
-		req.Body = io.NopCloser(buf)
-	}()
+	logged, size, rest, err := drainBody(req.Body, maxBytes, req.ContentLength)
+	req.Body = rest
 
-	if _, err := buf.ReadFrom(req.Body); err != nil {
-		return buf.Bytes(), err
-	}
+	return logged, size, err
+}
 
-	if err := req.Body.Close(); err != nil {
-		return buf.Bytes(), err
-	}
+func drainResponseBody(res *http.Response, maxBytes int64) ([]byte, int64, error) {
+	logged, size, rest, err := drainBody(res.Body, maxBytes, res.ContentLength)
+	res.Body = rest
 
-	return buf.Bytes(), nil
+	return logged, size, err
 }
 
-func drainResponseBody(req *http.Response) ([]byte, error) {
+// drainBody reads the body to log it, up to maxBytes (no limit when <= 0). It returns the logged bytes, the body size
+// and the body that replaces the drained one. A body longer than maxBytes is not read to its end: its size is the
+// content length (-1 when unknown) and the replacement streams the read bytes followed by the rest of the body.
+func drainBody(body io.ReadCloser, maxBytes, contentLength int64) ([]byte, int64, io.ReadCloser, error) {
 	buf := &bytes.Buffer{}
 
-	defer func() {
-		req.Body = io.NopCloser(buf)
-	}()
+	var src io.Reader = body
+	if maxBytes > 0 {
+		src = io.LimitReader(body, maxBytes+1)
+	}
+
+	if _, err := buf.ReadFrom(src); err != nil {
+		return buf.Bytes(), int64(buf.Len()), io.NopCloser(buf), err
+	}
 
-	if _, err := buf.ReadFrom(req.Body); err != nil {
-		return buf.Bytes(), err
+	if maxBytes > 0 && int64(buf.Len()) > maxBytes {
+		rest := &drainedBody{Reader: io.MultiReader(bytes.NewReader(buf.Bytes()), body), Closer: body}
+		return buf.Bytes()[:maxBytes], contentLength, rest, nil
 	}
 
-	if err := req.Body.Close(); err != nil {
-		return buf.Bytes(), err
+	if err := body.Close(); err != nil {
+		return buf.Bytes(), int64(buf.Len()), io.NopCloser(buf), err
 	}
 
-	return buf.Bytes(), nil
+	return buf.Bytes(), int64(buf.Len()), io.NopCloser(buf), nil
+}
+
+type drainedBody struct {
+	io.Reader
+	io.Closer
 }
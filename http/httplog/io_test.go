@@ -0,0 +1,82 @@
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainBody(t *testing.T) {
+	tests := map[string]struct {
+		body           string
+		maxBytes       int64
+		contentLength  int64
+		expectedLogged string
+		expectedSize   int64
+	}{
+		"no limit": {
+			body:           "0123456789",
+			contentLength:  -1,
+			expectedLogged: "0123456789",
+			expectedSize:   10,
+		},
+		"under the limit": {
+			body:           "0123456789",
+			maxBytes:       10,
+			contentLength:  -1,
+			expectedLogged: "0123456789",
+			expectedSize:   10,
+		},
+		"over the limit with content length": {
+			body:           "0123456789",
+			maxBytes:       4,
+			contentLength:  10,
+			expectedLogged: "0123",
+			expectedSize:   10,
+		},
+		"over the limit with unknown length": {
+			body:           "0123456789",
+			maxBytes:       4,
+			contentLength:  -1,
+			expectedLogged: "0123",
+			expectedSize:   -1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			logged, size, rest, err := drainBody(io.NopCloser(strings.NewReader(tc.body)), tc.maxBytes, tc.contentLength)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLogged, string(logged))
+			assert.Equal(t, tc.expectedSize, size)
+
+			// the whole body passes through.
+			b, err := io.ReadAll(rest)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(b))
+			require.NoError(t, rest.Close())
+		})
+	}
+}
+
+func TestTeeReadCloserMaxBytes(t *testing.T) {
+	var logged string
+	var loggedSize int64
+
+	pool := NewBytesBufferPool(16)
+	tee := newTeeReadCloserPooled(strings.NewReader("0123456789"), pool, 4, func(_, _ error, buf *bytes.Buffer, size int64) {
+		logged, loggedSize = buf.String(), size
+	})
+
+	b, err := io.ReadAll(tee)
+	require.NoError(t, err)
+	require.NoError(t, tee.Close())
+
+	assert.Equal(t, "0123456789", string(b))
+	assert.Equal(t, "0123", logged)
+	assert.Equal(t, int64(10), loggedSize)
+}
@@ -0,0 +1,190 @@
+package httplog
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// HeaderMatcherFunc is a [HeaderMatcher] signature alias.
+type HeaderMatcherFunc func(key string, values []string) bool
+
+// Match implements the HeaderMatcher interface.
+func (f HeaderMatcherFunc) Match(key string, values []string) bool {
+	return f(key, values)
+}
+
+// DefaultSensitiveHeaders matches the headers that carry credentials, to be used as the [LogPolicy] MaskedValueHeaders
+// or OmitHeaders.
+var DefaultSensitiveHeaders HeaderMatcher = HeaderNames(
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"Proxy-Authorization",
+	"X-Api-Key",
+)
+
+// HeaderNames matches the headers by their canonical name (case-insensitive).
+func HeaderNames(names ...string) HeaderMatcher {
+	set := make(headerNames, len(names))
+	for _, n := range names {
+		set[http.CanonicalHeaderKey(n)] = struct{}{}
+	}
+
+	return set
+}
+
+type headerNames map[string]struct{}
+
+func (s headerNames) Match(key string, _ []string) bool {
+	_, exists := s[http.CanonicalHeaderKey(key)]
+	return exists
+}
+
+// HeaderPrefix matches the headers whose name starts with any of the prefixes (case-insensitive), e.g. `X-Secret-`.
+func HeaderPrefix(prefixes ...string) HeaderMatcher {
+	prefixes = lowerAll(prefixes)
+
+	return HeaderMatcherFunc(func(key string, _ []string) bool {
+		key = strings.ToLower(key)
+		for _, p := range prefixes {
+			if strings.HasPrefix(key, p) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// HeaderSuffix matches the headers whose name ends with any of the suffixes (case-insensitive), e.g. `-Token`.
+func HeaderSuffix(suffixes ...string) HeaderMatcher {
+	suffixes = lowerAll(suffixes)
+
+	return HeaderMatcherFunc(func(key string, _ []string) bool {
+		key = strings.ToLower(key)
+		for _, s := range suffixes {
+			if strings.HasSuffix(key, s) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// HeaderRegexp matches the headers whose name matches the (case-insensitive) regular expression.
+func HeaderRegexp(pattern string) (HeaderMatcher, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return HeaderMatcherFunc(func(key string, _ []string) bool {
+		return re.MatchString(key)
+	}), nil
+}
+
+// MustHeaderRegexp is like [HeaderRegexp] but panics if the expression cannot be parsed.
+func MustHeaderRegexp(pattern string) HeaderMatcher {
+	m, err := HeaderRegexp(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// HeaderGlob matches the headers whose name matches any of the (case-insensitive) glob patterns, e.g. `X-*-Token`.
+// The pattern syntax is the [path.Match] one, the invalid patterns match nothing.
+func HeaderGlob(patterns ...string) HeaderMatcher {
+	patterns = lowerAll(patterns)
+
+	return HeaderMatcherFunc(func(key string, _ []string) bool {
+		key = strings.ToLower(key)
+		for _, p := range patterns {
+			if matched, _ := path.Match(p, key); matched {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// HeaderValue matches the headers that have any value for which the predicate is true, regardless of their name.
+func HeaderValue(predicate func(value string) bool) HeaderMatcher {
+	return HeaderMatcherFunc(func(_ string, values []string) bool {
+		for _, v := range values {
+			if predicate(v) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// HeaderValuePrefix matches the headers that have any value starting with any of the prefixes (case-insensitive).
+func HeaderValuePrefix(prefixes ...string) HeaderMatcher {
+	prefixes = lowerAll(prefixes)
+
+	return HeaderValue(func(value string) bool {
+		value = strings.ToLower(strings.TrimSpace(value))
+		for _, p := range prefixes {
+			if strings.HasPrefix(value, p) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// BearerToken matches the headers that carry a bearer token (`Bearer <token>`) in any header.
+func BearerToken() HeaderMatcher {
+	return HeaderValuePrefix("Bearer ")
+}
+
+// Any matches when any of the matchers matches.
+func Any(matchers ...HeaderMatcher) HeaderMatcher {
+	return HeaderMatcherFunc(func(key string, values []string) bool {
+		for _, m := range matchers {
+			if m.Match(key, values) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// All matches when all the matchers match (and there is at least one).
+func All(matchers ...HeaderMatcher) HeaderMatcher {
+	return HeaderMatcherFunc(func(key string, values []string) bool {
+		for _, m := range matchers {
+			if !m.Match(key, values) {
+				return false
+			}
+		}
+
+		return len(matchers) > 0
+	})
+}
+
+// Not negates the matcher.
+func Not(matcher HeaderMatcher) HeaderMatcher {
+	return HeaderMatcherFunc(func(key string, values []string) bool {
+		return !matcher.Match(key, values)
+	})
+}
+
+func lowerAll(s []string) []string {
+	lower := make([]string, len(s))
+	for i, v := range s {
+		lower[i] = strings.ToLower(v)
+	}
+
+	return lower
+}
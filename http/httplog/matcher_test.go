@@ -0,0 +1,150 @@
+package httplog
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderMatchers(t *testing.T) {
+	type header struct {
+		key      string
+		values   []string
+		expected bool
+	}
+
+	tests := map[string]struct {
+		matcher HeaderMatcher
+		headers []header
+	}{
+		"default sensitive headers": {
+			matcher: DefaultSensitiveHeaders,
+			headers: []header{
+				{key: "Authorization", expected: true},
+				{key: "cookie", expected: true},
+				{key: "SET-COOKIE", expected: true},
+				{key: "Proxy-Authorization", expected: true},
+				{key: "x-api-key", expected: true},
+				{key: "Content-Type", expected: false},
+			},
+		},
+		"names": {
+			matcher: HeaderNames("x-request-id"),
+			headers: []header{
+				{key: "X-Request-Id", expected: true},
+				{key: "X-Request-Ids", expected: false},
+			},
+		},
+		"prefix": {
+			matcher: HeaderPrefix("X-Secret-"),
+			headers: []header{
+				{key: "x-secret-one", expected: true},
+				{key: "X-Secrets", expected: false},
+			},
+		},
+		"suffix": {
+			matcher: HeaderSuffix("-token"),
+			headers: []header{
+				{key: "X-Auth-Token", expected: true},
+				{key: "X-Token-Id", expected: false},
+			},
+		},
+		"regexp": {
+			matcher: MustHeaderRegexp(`^x-(auth|csrf)-`),
+			headers: []header{
+				{key: "X-Auth-Token", expected: true},
+				{key: "X-CSRF-Token", expected: true},
+				{key: "X-Request-Id", expected: false},
+			},
+		},
+		"glob": {
+			matcher: HeaderGlob("X-*-Token", "[", "*key"),
+			headers: []header{
+				{key: "x-auth-token", expected: true},
+				{key: "X-Api-Key", expected: true},
+				{key: "X-Auth", expected: false},
+			},
+		},
+		"bearer token": {
+			matcher: BearerToken(),
+			headers: []header{
+				{key: "Authorization", values: []string{"Basic abc"}, expected: false},
+				{key: "X-Forwarded-Authorization", values: []string{"Basic abc", " bearer abc"}, expected: true},
+				{key: "Authorization", expected: false},
+			},
+		},
+		"value": {
+			matcher: HeaderValue(func(v string) bool { return strings.Contains(v, "secret") }),
+			headers: []header{
+				{key: "X-Note", values: []string{"a secret"}, expected: true},
+				{key: "X-Note", values: []string{"public"}, expected: false},
+			},
+		},
+		"any": {
+			matcher: Any(HeaderNames("Cookie"), HeaderPrefix("X-Secret-")),
+			headers: []header{
+				{key: "Cookie", expected: true},
+				{key: "X-Secret-A", expected: true},
+				{key: "Accept", expected: false},
+			},
+		},
+		"all": {
+			matcher: All(HeaderNames("Authorization"), BearerToken()),
+			headers: []header{
+				{key: "Authorization", values: []string{"Bearer t"}, expected: true},
+				{key: "Authorization", values: []string{"Basic t"}, expected: false},
+				{key: "X-Token", values: []string{"Bearer t"}, expected: false},
+			},
+		},
+		"all without matchers": {
+			matcher: All(),
+			headers: []header{{key: "Accept", expected: false}},
+		},
+		"not": {
+			matcher: Not(HeaderNames("Accept", "Content-Type")),
+			headers: []header{
+				{key: "Accept", expected: false},
+				{key: "Authorization", expected: true},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, h := range tc.headers {
+				assert.Equal(t, h.expected, tc.matcher.Match(h.key, h.values), "header %s: %v", h.key, h.values)
+			}
+		})
+	}
+}
+
+func TestHeaderRegexpInvalid(t *testing.T) {
+	_, err := HeaderRegexp(`(`)
+	require.Error(t, err)
+
+	assert.Panics(t, func() { MustHeaderRegexp(`(`) })
+}
+
+func TestHeaderMatchersInLogPolicy(t *testing.T) {
+	a := HTTPSLogAttrsConverter{logPolicy: LogPolicy{
+		OmitHeaders:        HeaderNames("Cookie"),
+		MaskedValueHeaders: Any(DefaultSensitiveHeaders, BearerToken()),
+	}}
+
+	h := http.Header{
+		"Authorization":   {"Basic abc"},
+		"Cookie":          {"a=b"},
+		"X-Forwarded-Jwt": {"Bearer abc"},
+		"Accept":          {"*/*"},
+	}
+
+	logged := map[string]string{}
+	for _, attr := range a.Headers("headers", h).Value.Group() {
+		logged[attr.Key] = attr.Value.String()
+	}
+
+	assert.Equal(t, map[string]string{"Accept": "*/*", "Authorization": "***", "X-Forwarded-Jwt": "***"}, logged)
+}
@@ -53,14 +53,14 @@ func (il *HTTPLogger) loggerRoundTripperTee(next http.RoundTripper) RoundTripper
 	return func(req *http.Request) (*http.Response, error) {
 		reqAttrs := il.attrConverter.AttrsHTTPRequestExcludeBody(req)
 
-		req.Body = NewTeeReadCloserPooled(req.Body, il.pool, func(readErr, closeErr error, buf *bytes.Buffer) {
+		req.Body = newTeeReadCloserPooled(req.Body, il.pool, il.logPolicy.MaxRequestBodyLogBytes, func(readErr, closeErr error, buf *bytes.Buffer, size int64) {
 			if readErr != nil {
 				reqAttrs = append(reqAttrs, attrError("readError", readErr))
 			}
 			if closeErr != nil {
 				reqAttrs = append(reqAttrs, attrError("closeError", closeErr))
 			}
-			reqAttrs = append(reqAttrs, attrBody(buf.Bytes()))
+			reqAttrs = append(reqAttrs, il.attrConverter.attrBody(req.Header.Get("Content-Type"), buf.Bytes(), size))
 		})
 
 		startTime := time.Now()
@@ -77,14 +77,14 @@ func (il *HTTPLogger) loggerRoundTripperTee(next http.RoundTripper) RoundTripper
 		attrs = append(attrs, il.attrConverter.GroupAttrsAsHTTPRequest(reqAttrs)) // request
 
 		resAttrs := il.attrConverter.AttrsHTTPResponseExcludeBody(res)
-		res.Body = NewTeeReadCloserPooled(res.Body, il.pool, func(readErr, closeErr error, buf *bytes.Buffer) {
+		res.Body = newTeeReadCloserPooled(res.Body, il.pool, il.logPolicy.MaxResponseBodyLogBytes, func(readErr, closeErr error, buf *bytes.Buffer, size int64) {
 			if readErr != nil {
 				resAttrs = append(resAttrs, attrError("readError", readErr))
 			}
 			if closeErr != nil {
 				resAttrs = append(resAttrs, attrError("closeError", closeErr))
 			}
-			resAttrs = append(resAttrs, attrBody(buf.Bytes()))
+			resAttrs = append(resAttrs, il.attrConverter.attrBody(res.Header.Get("Content-Type"), buf.Bytes(), size))
 
 			attrs = append(attrs, il.attrConverter.GroupAttrsAsHTTPResponse(resAttrs)) // response
 
@@ -98,3 +98,8 @@ func (il *HTTPLogger) loggerRoundTripperTee(next http.RoundTripper) RoundTripper
 func (il *HTTPLogger) logOutbound(ctx context.Context, attrs []slog.Attr) {
 	il.logger.LogAttrs(ctx, il.logInLevel.Level(), "http outbound", attrs...)
 }
+
+// LogEvent logs an event that is not an http exchange (e.g. a round tripper state change), with the logger and the level of the HTTPLogger.
+func (il *HTTPLogger) LogEvent(ctx context.Context, msg string, attrs ...slog.Attr) {
+	il.logger.LogAttrs(ctx, il.logInLevel.Level(), msg, attrs...)
+}
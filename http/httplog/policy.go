@@ -1,6 +1,7 @@
 package httplog
 
 import (
+	"mime"
 	"net/http"
 	"strings"
 )
@@ -9,12 +10,80 @@ type HeaderMatcher interface {
 	Match(key string, values []string) bool
 }
 
+// QueryMatcher matches the url query parameters, with the decoded key and values.
+type QueryMatcher interface {
+	Match(key string, values []string) bool
+}
+
+// QueryMatcherFunc is a [QueryMatcher] signature alias.
+type QueryMatcherFunc func(key string, values []string) bool
+
+// Match implements the QueryMatcher interface.
+func (f QueryMatcherFunc) Match(key string, values []string) bool {
+	return f(key, values)
+}
+
 type LogPolicy struct {
 	RequestBodyLogPolicy        RequestBodyLogPolicy
 	ResponseBodyLogPolicy       ResponseBodyLogPolicy
 	ResponseWriterBodyLogPolicy ResponseWriterBodyLogPolicy
 	OmitHeaders                 HeaderMatcher
 	MaskedValueHeaders          HeaderMatcher
+	// LogQuery logs the url query parameters (in the url group and its full attribute) filtered by the AllowedQueryParams
+	// and the OmitQueryParams, and masked by the MaskedValueQueryParams. The pairs that [url.ParseQuery] rejects (e.g. with
+	// a `;`) are not logged. When any of these is set, the inbound requestUri is logged with the same query (or without
+	// it, if LogQuery is false), and the form values are omitted and masked by the same matchers.
+	LogQuery               bool
+	AllowedQueryParams     QueryMatcher
+	OmitQueryParams        QueryMatcher
+	MaskedValueQueryParams QueryMatcher
+	// LogURLUser logs the url user info, with the password masked.
+	LogURLUser bool
+	// MaxRequestBodyLogBytes caps the logged (and buffered) bytes of the request bodies. The rest of the body still
+	// streams through, and the body is logged as truncated along with its size. Zero or negative means no limit.
+	MaxRequestBodyLogBytes int64
+	// MaxResponseBodyLogBytes is the MaxRequestBodyLogBytes of the response bodies.
+	MaxResponseBodyLogBytes int64
+	// BodyRedactor rewrites the logged bodies, e.g. a [NewRedactor] that masks the secrets of json, form and xml bodies.
+	BodyRedactor BodyRedactor
+	// BodyFormat is how the json bodies are logged (default [BodyAsString]).
+	BodyFormat BodyFormat
+	// MaxStructuredBodyBytes is the maximum size of a json body that is logged structured (by BodyFormat). Longer ones
+	// are logged as strings. Zero means [DefaultMaxStructuredBodyBytes], negative means no limit.
+	MaxStructuredBodyBytes int64
+}
+
+// BodyFormat is how the json (application/json and +json) bodies are logged. The bodies that are truncated, not valid
+// json or longer than the LogPolicy MaxStructuredBodyBytes are always logged as strings.
+type BodyFormat int
+
+const (
+	// BodyAsString logs the bodies as (quoted to graphic) strings.
+	BodyAsString BodyFormat = iota
+	// BodyAsGroup logs the json objects as nested [slog.Group] values, so every handler emits their fields as attributes.
+	// The arrays are logged as []any values.
+	BodyAsGroup
+	// BodyAsRawJSON logs the json bodies as [json.RawMessage], which the [slog.JSONHandler] emits as they are (compacted).
+	BodyAsRawJSON
+)
+
+const DefaultMaxStructuredBodyBytes = 64 << 10
+
+func (l LogPolicy) structuredBody(contentType string, size int) bool {
+	if l.BodyFormat == BodyAsString {
+		return false
+	}
+
+	switch maxBytes := l.MaxStructuredBodyBytes; {
+	case maxBytes == 0 && size > DefaultMaxStructuredBodyBytes:
+		return false
+	case maxBytes > 0 && int64(size) > maxBytes:
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
 }
 
 func (l LogPolicy) ShouldOmitHeader(key string, values []string) bool {
@@ -33,6 +102,41 @@ func (l LogPolicy) ShouldMaskHeader(key string, values []string) bool {
 	return l.MaskedValueHeaders.Match(key, values)
 }
 
+// ShouldOmitQueryParam reports whether the query parameter is not logged: it is not allowed (when AllowedQueryParams
+// is set) or it is omitted.
+func (l LogPolicy) ShouldOmitQueryParam(key string, values []string) bool {
+	if l.AllowedQueryParams != nil && !l.AllowedQueryParams.Match(key, values) {
+		return true
+	}
+
+	if l.OmitQueryParams == nil {
+		return false
+	}
+
+	return l.OmitQueryParams.Match(key, values)
+}
+
+func (l LogPolicy) ShouldMaskQueryParam(key string, values []string) bool {
+	if l.MaskedValueQueryParams == nil {
+		return false
+	}
+
+	return l.MaskedValueQueryParams.Match(key, values)
+}
+
+// filtersQuery reports whether the query parameters are configured, so the raw queries are not logged as they are.
+func (l LogPolicy) filtersQuery() bool {
+	return l.LogQuery || l.AllowedQueryParams != nil || l.OmitQueryParams != nil || l.MaskedValueQueryParams != nil
+}
+
+func (l LogPolicy) RedactBody(contentType string, body []byte) []byte {
+	if l.BodyRedactor == nil || len(body) == 0 {
+		return body
+	}
+
+	return l.BodyRedactor.Redact(contentType, body)
+}
+
 func (l LogPolicy) ShouldLogRequestBody(r *http.Request) bool {
 	if l.RequestBodyLogPolicy == nil {
 		return DefaultRequestBodyLogPolicy(r)
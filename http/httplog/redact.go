@@ -0,0 +1,462 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// BodyRedactor rewrites a body before it is logged, e.g. to mask the secrets it holds.
+// The body might be truncated ([LogPolicy] MaxRequestBodyLogBytes and MaxResponseBodyLogBytes).
+type BodyRedactor interface {
+	Redact(contentType string, body []byte) []byte
+}
+
+// BodyRedactorFunc is a [BodyRedactor] signature alias.
+type BodyRedactorFunc func(contentType string, body []byte) []byte
+
+// Redact implements the BodyRedactor interface.
+func (f BodyRedactorFunc) Redact(contentType string, body []byte) []byte {
+	return f(contentType, body)
+}
+
+// DefaultRedactMask is the value that replaces the redacted values.
+const DefaultRedactMask = "***"
+
+type RedactorOption func(r *Redactor)
+
+// WithRedactPaths redacts the values in the given json paths, e.g. `$.user.password`, `$.cards[*].number` or `$.*.token`.
+// A `*` segment matches any key or index and a `[*]` segment any index. The xml paths start from the root element, and
+// the last segment can be an attribute name. The form paths have a single segment, the field name.
+func WithRedactPaths(paths ...string) RedactorOption {
+	return func(r *Redactor) {
+		for _, p := range paths {
+			r.paths = append(r.paths, parseRedactPath(p))
+		}
+	}
+}
+
+// WithRedactKeys redacts the values of the keys (json object keys, form fields, xml elements and attributes) that match
+// any of the case-insensitive glob patterns (e.g. `password`, `*token*`), in any depth.
+func WithRedactKeys(patterns ...string) RedactorOption {
+	return func(r *Redactor) {
+		for _, p := range patterns {
+			r.keys = append(r.keys, strings.ToLower(p))
+		}
+	}
+}
+
+// WithRedactMask sets the value that replaces the redacted values (default [DefaultRedactMask]).
+func WithRedactMask(mask string) RedactorOption {
+	return func(r *Redactor) {
+		r.mask = mask
+	}
+}
+
+// WithMediaTypeRedactor sets the redactor of the bodies of the media type (e.g. `application/xml`), replacing the
+// default one or adding a new one.
+func WithMediaTypeRedactor(mediaType string, redactor BodyRedactor) RedactorOption {
+	return func(r *Redactor) {
+		r.mediaTypes[strings.ToLower(mediaType)] = redactor
+	}
+}
+
+// NewRedactor returns a [BodyRedactor] that redacts the values of the json (application/json, +json and
+// application/x-ndjson), the form (application/x-www-form-urlencoded) and the xml (application/xml, text/xml and +xml)
+// bodies that match the paths or the key patterns. The bodies of other media types are logged as they are, unless a
+// redactor is set with [WithMediaTypeRedactor].
+//
+//	policy := LogPolicy{
+//		BodyRedactor: NewRedactor(WithRedactPaths("$.user.password"), WithRedactKeys("*token*")),
+//	}
+func NewRedactor(opts ...RedactorOption) *Redactor {
+	r := &Redactor{
+		mask:       DefaultRedactMask,
+		mediaTypes: map[string]BodyRedactor{},
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+type Redactor struct {
+	paths      [][]string
+	keys       []string
+	mask       string
+	mediaTypes map[string]BodyRedactor
+}
+
+func (r *Redactor) Redact(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if redactor, exists := r.mediaTypes[mediaType]; exists {
+		return redactor.Redact(contentType, body)
+	}
+
+	switch {
+	case mediaType == "application/json", mediaType == "application/x-ndjson", strings.HasSuffix(mediaType, "+json"):
+		return r.RedactJSON(body)
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.RedactForm(body)
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		return r.RedactXML(body)
+	default:
+		return body
+	}
+}
+
+// redacts reports whether the value in the path is redacted.
+func (r *Redactor) redacts(p []string) bool {
+	if len(p) == 0 {
+		return false
+	}
+
+	if key := p[len(p)-1]; !isIndexSegment(key) {
+		key = strings.ToLower(key)
+		for _, pattern := range r.keys {
+			if matched, _ := path.Match(pattern, key); matched {
+				return true
+			}
+		}
+	}
+
+	for _, pattern := range r.paths {
+		if matchRedactPath(pattern, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactJSON redacts the json values (or the concatenated json values, e.g. ndjson). The output is compacted.
+// An invalid (e.g. truncated) json body is redacted up to the first error, the rest of it is dropped.
+func (r *Redactor) RedactJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	out := &bytes.Buffer{}
+	out.Grow(len(body))
+
+	var stack []*jsonFrame
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return out.Bytes()
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if d, isDelim := tok.(json.Delim); isDelim && (d == '}' || d == ']') {
+			out.WriteByte(byte(d))
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].valueDone()
+			}
+			continue
+		}
+
+		if top != nil && top.object && top.expectKey {
+			key, _ := tok.(string)
+			if top.n > 0 {
+				out.WriteByte(',')
+			}
+			writeJSONString(out, key)
+			out.WriteByte(':')
+			top.key, top.expectKey = key, false
+			continue
+		}
+
+		switch {
+		case top == nil && out.Len() > 0:
+			out.WriteByte('\n')
+		case top != nil && !top.object && top.n > 0:
+			out.WriteByte(',')
+		}
+
+		if r.redacts(jsonPath(stack)) {
+			writeJSONString(out, r.mask)
+			if _, isDelim := tok.(json.Delim); isDelim && skipJSONValue(dec) != nil {
+				return out.Bytes()
+			}
+			top.valueDone()
+			continue
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			out.WriteByte(byte(t))
+			stack = append(stack, &jsonFrame{object: t == '{', expectKey: t == '{'})
+			continue
+		case string:
+			writeJSONString(out, t)
+		case json.Number:
+			out.WriteString(t.String())
+		case bool:
+			out.WriteString(strconv.FormatBool(t))
+		case nil:
+			out.WriteString("null")
+		}
+
+		top.valueDone()
+	}
+}
+
+// jsonFrame is an open json object or array.
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+	// n is the number of the values written so far, which is the index of the next array value.
+	n int
+}
+
+func (f *jsonFrame) valueDone() {
+	if f == nil {
+		return
+	}
+
+	f.n++
+	f.expectKey = f.object
+}
+
+func jsonPath(stack []*jsonFrame) []string {
+	p := make([]string, 0, len(stack))
+	for _, f := range stack {
+		if f.object {
+			p = append(p, f.key)
+		} else {
+			p = append(p, "["+strconv.Itoa(f.n)+"]")
+		}
+	}
+
+	return p
+}
+
+// skipJSONValue consumes the tokens of the object or array that has just been opened.
+func skipJSONValue(dec *json.Decoder) error {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	out.Truncate(out.Len() - 1) // the encoder's new line.
+}
+
+// RedactForm redacts the values of the url encoded form fields. The rest of the body is kept as it is. The mask is written
+// as is (not url encoded), so the redacted values are logged the same in every body type.
+func (r *Redactor) RedactForm(body []byte) []byte {
+	pairs := strings.Split(string(body), "&")
+	for i, pair := range pairs {
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+
+		if r.redacts([]string{key}) {
+			pairs[i] = rawKey + "=" + r.mask
+		}
+	}
+
+	return []byte(strings.Join(pairs, "&"))
+}
+
+// RedactXML redacts the xml elements (their content) and attributes. The namespace prefixes are kept and the paths
+// and keys are matched against the local names. An invalid (e.g. truncated) xml body is redacted up to the first
+// error, the rest of it is dropped.
+func (r *Redactor) RedactXML(body []byte) []byte {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	out := &bytes.Buffer{}
+	out.Grow(len(body))
+
+	var elements []string
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return out.Bytes()
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			elements = append(elements, t.Name.Local)
+			r.writeXMLStart(out, t, elements)
+
+			if !r.redacts(elements) {
+				continue
+			}
+
+			_ = xml.EscapeText(out, []byte(r.mask))
+			if skipXMLElement(dec) != nil {
+				return out.Bytes()
+			}
+			writeXMLEnd(out, t.Name)
+			elements = elements[:len(elements)-1]
+		case xml.EndElement:
+			writeXMLEnd(out, t.Name)
+			if len(elements) > 0 {
+				elements = elements[:len(elements)-1]
+			}
+		case xml.CharData:
+			_ = xml.EscapeText(out, t)
+		case xml.Comment:
+			out.WriteString("<!--")
+			out.Write(t)
+			out.WriteString("-->")
+		case xml.ProcInst:
+			out.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				out.WriteByte(' ')
+				out.Write(t.Inst)
+			}
+			out.WriteString("?>")
+		case xml.Directive:
+			out.WriteString("<!")
+			out.Write(t)
+			out.WriteByte('>')
+		}
+	}
+}
+
+func (r *Redactor) writeXMLStart(out *bytes.Buffer, t xml.StartElement, elements []string) {
+	out.WriteByte('<')
+	out.WriteString(xmlName(t.Name))
+
+	for _, a := range t.Attr {
+		value := a.Value
+		if a.Name.Space != "xmlns" && r.redacts(append(elements[:len(elements):len(elements)], a.Name.Local)) {
+			value = r.mask
+		}
+
+		out.WriteString(" " + xmlName(a.Name) + `="`)
+		_ = xml.EscapeText(out, []byte(value))
+		out.WriteByte('"')
+	}
+
+	out.WriteByte('>')
+}
+
+func writeXMLEnd(out *bytes.Buffer, name xml.Name) {
+	out.WriteString("</" + xmlName(name) + ">")
+}
+
+// xmlName is the raw (prefixed) name of a [xml.Decoder.RawToken].
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return n.Space + ":" + n.Local
+}
+
+// skipXMLElement consumes the tokens up to the end of the element that has just been started.
+func skipXMLElement(dec *xml.Decoder) error {
+	for depth := 1; depth > 0; {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return err
+		}
+
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// parseRedactPath splits a json path (e.g. `$.users[*].password` or `$['a.b'].c`) into its segments.
+func parseRedactPath(p string) []string {
+	p = strings.TrimPrefix(strings.TrimSpace(p), "$")
+
+	var segments []string
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			segments = append(segments, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				segments = append(segments, p)
+				p = ""
+				continue
+			}
+			segment := p[:end+1]
+			if inner := strings.Trim(segment[1:len(segment)-1], `'"`); inner != segment[1:len(segment)-1] {
+				segment = inner // a quoted key.
+			}
+			segments = append(segments, segment)
+			p = p[end+1:]
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			segments = append(segments, p[:end])
+			p = p[end:]
+		}
+	}
+
+	return segments
+}
+
+func matchRedactPath(pattern, p []string) bool {
+	if len(pattern) != len(p) {
+		return false
+	}
+
+	for i, segment := range pattern {
+		switch {
+		case segment == "*":
+		case segment == "[*]" && isIndexSegment(p[i]):
+		case segment == p[i]:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func isIndexSegment(s string) bool {
+	return strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]")
+}
@@ -0,0 +1,150 @@
+package httplog
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor(
+		WithRedactPaths("$.user.password", "$.cards[*].number", "$['a.b']"),
+		WithRedactKeys("*token*", "secret"),
+	)
+
+	tests := map[string]struct {
+		contentType string
+		body        string
+		expected    string
+	}{
+		"json paths and keys": {
+			contentType: "application/json; charset=utf-8",
+			body:        `{"user":{"name":"a","password":"p<>"},"cards":[{"number":"4111","cvc":1}],"Access_Token":{"x":[1,2]},"a.b":true,"n":null}`,
+			expected:    `{"user":{"name":"a","password":"***"},"cards":[{"number":"***","cvc":1}],"Access_Token":"***","a.b":"***","n":null}`,
+		},
+		"json path does not match other depths": {
+			contentType: "application/problem+json",
+			body:        `{"password":"p","x":{"user":{"password":"p"}}}`,
+			expected:    `{"password":"p","x":{"user":{"password":"p"}}}`,
+		},
+		"json array root": {
+			contentType: "application/json",
+			body:        `[{"secret":"s"},{"secret":["s"]}, 1.50]`,
+			expected:    `[{"secret":"***"},{"secret":"***"},1.50]`,
+		},
+		"truncated json": {
+			contentType: "application/json",
+			body:        `{"name":"a","token":"abcdef`,
+			expected:    `{"name":"a","token":`,
+		},
+		"ndjson": {
+			contentType: "application/x-ndjson",
+			body:        "{\"token\":\"a\"}\n{\"token\":\"b\"}\n",
+			expected:    "{\"token\":\"***\"}\n{\"token\":\"***\"}",
+		},
+		"form": {
+			contentType: "application/x-www-form-urlencoded",
+			body:        "user=a&Secret=s%20s&refresh_token=t&x",
+			expected:    "user=a&Secret=***&refresh_token=***&x",
+		},
+		"xml": {
+			contentType: "application/soap+xml",
+			body:        `<?xml version="1.0"?><user id="1" token="t"><!-- c --><password><b>p</b></password><ns:secret xmlns:ns="urn:x">s</ns:secret><name>a &amp; b</name><empty/></user>`,
+			expected:    `<?xml version="1.0"?><user id="1" token="***"><!-- c --><password>***</password><ns:secret xmlns:ns="urn:x">***</ns:secret><name>a &amp; b</name><empty></empty></user>`,
+		},
+		"xml path": {
+			contentType: "text/xml",
+			body:        `<user><password>p</password><name>a</name></user>`,
+			expected:    `<user><password>***</password><name>a</name></user>`,
+		},
+		"other media types": {
+			contentType: "text/plain",
+			body:        `token=abc`,
+			expected:    `token=abc`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(redactor.Redact(tc.contentType, []byte(tc.body))))
+		})
+	}
+}
+
+func TestRedactorMediaTypeRedactor(t *testing.T) {
+	redactor := NewRedactor(
+		WithRedactMask("[redacted]"),
+		WithMediaTypeRedactor("text/plain", BodyRedactorFunc(func(_ string, _ []byte) []byte { return []byte("[redacted]") })),
+	)
+
+	assert.Equal(t, "[redacted]", string(redactor.Redact("text/plain; charset=utf-8", []byte("secret"))))
+}
+
+func TestParseRedactPath(t *testing.T) {
+	tests := map[string][]string{
+		"$.user.password":    {"user", "password"},
+		"$.cards[*].number":  {"cards", "[*]", "number"},
+		"$['a.b'].c":         {"a.b", "c"},
+		"$.items[0]":         {"items", "[0]"},
+		"password":           {"password"},
+		"$.broken[":          {"broken", "["},
+		`$["x"]`:             {"x"},
+		"$.*.token":          {"*", "token"},
+		"$.user..password":   {"user", "", "password"},
+		"$.a[1][2]":          {"a", "[1]", "[2]"},
+		"$":                  nil,
+		" $.user.password  ": {"user", "password"},
+	}
+
+	for p, expected := range tests {
+		t.Run(p, func(t *testing.T) {
+			assert.Equal(t, expected, parseRedactPath(p))
+		})
+	}
+}
+
+func TestLogPolicyBodyRedactor(t *testing.T) {
+	logs := &bytes.Buffer{}
+	il := NewHTTPLogger(
+		WithLogger(slog.New(slog.NewJSONHandler(logs, nil))),
+		WithLogInLevel(slog.LevelInfo),
+		WithLogPolicy(LogPolicy{BodyRedactor: NewRedactor(WithRedactKeys("password"))}),
+	)
+
+	req, err := http.NewRequest(http.MethodPost, "https://domain.test/login", strings.NewReader(`{"user":"a","password":"p"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rt := il.LoggerRoundTripper(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       http.NoBody,
+			Request:    r,
+		}, nil
+	}))
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	records := parseLogJSONLines(t, logs)
+	require.Len(t, records, 1)
+	assert.Equal(t,
+		map[string]any{"size": float64(27), "value": `{\"user\":\"a\",\"password\":\"***\"}`},
+		records[0]["request"].(map[string]any)["body"], //nolint:forcetypeassert // test.
+	)
+
+	// the body that is sent is not redacted.
+	b, err := req.GetBody()
+	require.NoError(t, err)
+	sent := &bytes.Buffer{}
+	_, err = sent.ReadFrom(b)
+	require.NoError(t, err)
+	assert.Equal(t, `{"user":"a","password":"p"}`, sent.String())
+}
@@ -32,6 +32,11 @@ type ResponseWriterWrapper interface {
 }
 
 func NewResponseWriterWrapper(w http.ResponseWriter) ResponseWriterWrapper {
+	return newResponseWriterWrapper(w, 0)
+}
+
+// newResponseWriterWrapper buffers up to maxBytes (no limit when <= 0) of the written body.
+func newResponseWriterWrapper(w http.ResponseWriter, maxBytes int64) ResponseWriterWrapper {
 	asFlusher, isFlusher := w.(http.Flusher)
 	asPusher, isPusher := w.(http.Pusher)
 	asReaderFrom, isReaderFrom := w.(io.ReaderFrom)
@@ -46,8 +51,9 @@ func NewResponseWriterWrapper(w http.ResponseWriter) ResponseWriterWrapper {
 	var wrapperNetHTTPResponse *netHTTPResponseWrapper
 
 	wrapperResponseWriter = &responseWriterWrapper{
-		wrapped: w,
-		tee:     &bytes.Buffer{},
+		wrapped:  w,
+		tee:      &bytes.Buffer{},
+		maxBytes: maxBytes,
 	}
 
 	if isFlusher {
@@ -189,6 +195,7 @@ var _ http.ResponseWriter = (*responseWriterWrapper)(nil)
 type responseWriterWrapper struct {
 	wrapped     http.ResponseWriter
 	tee         *bytes.Buffer
+	maxBytes    int64
 	statusCode  int
 	bytes       int
 	wroteHeader bool
@@ -216,8 +223,8 @@ func (w *responseWriterWrapper) Write(buf []byte) (n int, err error) {
 	}
 
 	n, err = w.wrapped.Write(buf)
-	if w.tee != nil {
-		_, teeErr := w.tee.Write(buf[:n])
+	if t := w.teeable(n); t > 0 {
+		_, teeErr := w.tee.Write(buf[:t])
 		err = errors.Join(err, teeErr)
 	}
 
@@ -225,6 +232,15 @@ func (w *responseWriterWrapper) Write(buf []byte) (n int, err error) {
 	return n, err
 }
 
+// teeable returns how many of the n written bytes fit in the tee buffer without exceeding maxBytes.
+func (w *responseWriterWrapper) teeable(n int) int {
+	if w.maxBytes <= 0 {
+		return n
+	}
+
+	return int(max(min(int64(n), w.maxBytes-int64(w.tee.Len())), 0))
+}
+
 func (w *responseWriterWrapper) Header() http.Header {
 	return w.wrapped.Header()
 }
@@ -285,9 +301,7 @@ type readerFrom struct {
 }
 
 func (w *readerFrom) ReadFrom(r io.Reader) (int64, error) {
-	n, err := io.Copy(w.w, r) // tunnel to responseWriterWrapper.Write
-	w.w.bytes += int(n)
-	return n, err
+	return io.Copy(w.w, r) // tunnel to responseWriterWrapper.Write (that counts the bytes)
 }
 
 // ### *net.response
@@ -1,50 +1,213 @@
 package http
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 )
 
+// PanicInfo describes a recovered panic.
+type PanicInfo struct {
+	// Value is the recovered value.
+	Value any
+	// Stack is the stack trace of the panicking goroutine. It is captured when [WithRecovererStackTrace] is enabled
+	// or a [WithRecovererOnPanic] hook is set.
+	Stack []byte
+	// HeaderWritten reports whether the handler had already written the response header before the panic.
+	HeaderWritten bool
+	// Hijacked reports whether the handler had hijacked the connection before the panic.
+	Hijacked bool
+}
+
+// RecoverResponseFunc writes the response of a recovered panic. It is called only when the response header has not
+// been written yet and the connection is not hijacked.
+type RecoverResponseFunc func(w http.ResponseWriter, r *http.Request, info PanicInfo)
+
+type RecovererOption func(c *recovererConfig)
+
+type recovererConfig struct {
+	stackTrace bool
+	response   RecoverResponseFunc
+	onPanic    []func(r *http.Request, info PanicInfo)
+}
+
+// WithRecovererStackTrace captures the stack trace of the panics and logs it in the "stack" attribute.
+func WithRecovererStackTrace(enabled bool) RecovererOption {
+	return func(c *recovererConfig) {
+		c.stackTrace = enabled
+	}
+}
+
+// WithRecovererResponse sets the function that writes the response of a recovered panic. The default writes a 500 problem details.
+func WithRecovererResponse(fn RecoverResponseFunc) RecovererOption {
+	return func(c *recovererConfig) {
+		c.response = fn
+	}
+}
+
+// WithRecovererOnPanic adds a hook (e.g. for alerting) that is called on every recovered panic, after it is logged.
+// The hooks are not called for [http.ErrAbortHandler].
+func WithRecovererOnPanic(fn func(r *http.Request, info PanicInfo)) RecovererOption {
+	return func(c *recovererConfig) {
+		c.onPanic = append(c.onPanic, fn)
+	}
+}
+
+// RecoverStatusResponse writes a bare 500 response.
+func RecoverStatusResponse(w http.ResponseWriter, _ *http.Request, _ PanicInfo) {
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// RecoverJSONResponse writes a 500 application/problem+json ([ProblemDetails]) response. It is the default [RecoverResponseFunc].
+func RecoverJSONResponse(w http.ResponseWriter, _ *http.Request, _ PanicInfo) {
+	_ = WriteProblemDetails(w, NewProblemDetails(http.StatusInternalServerError, ""))
+}
+
 // Recoverer returns a recoverer http middleware that logs every panic into the provided slog logger.
-func Recoverer(logger *slog.Logger) func(http.Handler) http.Handler {
+//
+// When the panic happens before the response header is written, the response is written by the [RecoverResponseFunc]
+// (a 500 problem details by default). When the header has already been written, the response can not be replaced, so after
+// logging the recoverer panics with [http.ErrAbortHandler] to make the server abort the response (instead of sending
+// a truncated one as complete). When the connection has been hijacked, nothing is written.
+func Recoverer(logger *slog.Logger, opts ...RecovererOption) func(http.Handler) http.Handler {
+	cfg := &recovererConfig{
+		response: RecoverJSONResponse,
+	}
+
+	for _, o := range opts {
+		o(cfg)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &recovererResponseWriter{w: w}
+
 			defer func(ctx context.Context) {
-				if rvr := recover(); rvr != nil {
-					// if the handler has already written the header this call is noop.
-					w.WriteHeader(http.StatusInternalServerError)
-
-					var recoverType slog.Value
-					var recoverVal slog.Value
-
-					recoverType = slog.StringValue(reflect.TypeOf(rvr).String())
-					if err, is := rvr.(error); is {
-						if errors.Is(err, http.ErrAbortHandler) {
-							return
-						}
-						recoverVal = slog.StringValue(err.Error())
-					} else {
-						recoverVal = slog.AnyValue(rvr)
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+
+				info := PanicInfo{
+					Value:         rvr,
+					HeaderWritten: rw.wroteHeader,
+					Hijacked:      rw.hijacked,
+				}
+
+				if err, is := rvr.(error); is && errors.Is(err, http.ErrAbortHandler) {
+					if info.HeaderWritten {
+						panic(rvr)
+					}
+					if !info.Hijacked {
+						cfg.response(w, r, info)
 					}
+					return
+				}
+
+				if cfg.stackTrace || len(cfg.onPanic) > 0 {
+					info.Stack = debug.Stack()
+				}
+
+				logger.LogAttrs(ctx, slog.LevelError, "recovered from panic", cfg.logAttrs(info)...)
 
-					logger.ErrorContext(
-						ctx,
-						"recovered from panic",
-						slog.Attr{Key: "recover_type", Value: recoverType},
-						slog.Attr{Key: "recover", Value: recoverVal},
-					)
+				for _, fn := range cfg.onPanic {
+					fn(r, info)
 				}
 
-				// Maybe: explore hijacking the connection.
-				// if hijacker, ok := w.(http.Hijacker); !ok {
-				// 	hijacker.Hijack()
-				// }
+				switch {
+				case info.Hijacked:
+					// the connection is owned by the handler.
+				case info.HeaderWritten:
+					panic(http.ErrAbortHandler)
+				default:
+					cfg.response(w, r, info)
+				}
 			}(r.Context())
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(rw, r)
 		})
 	}
 }
+
+func (c *recovererConfig) logAttrs(info PanicInfo) []slog.Attr {
+	recoverType := slog.StringValue(reflect.TypeOf(info.Value).String())
+
+	var recoverVal slog.Value
+	if err, is := info.Value.(error); is {
+		recoverVal = slog.StringValue(err.Error())
+	} else {
+		recoverVal = slog.AnyValue(info.Value)
+	}
+
+	attrs := []slog.Attr{
+		{Key: "recover_type", Value: recoverType},
+		{Key: "recover", Value: recoverVal},
+	}
+
+	if info.HeaderWritten {
+		attrs = append(attrs, slog.Bool("header_written", true))
+	}
+
+	if info.Hijacked {
+		attrs = append(attrs, slog.Bool("hijacked", true))
+	}
+
+	if c.stackTrace {
+		attrs = append(attrs, slog.String("stack", string(info.Stack)))
+	}
+
+	return attrs
+}
+
+var (
+	_ http.ResponseWriter = (*recovererResponseWriter)(nil)
+	_ http.Flusher        = (*recovererResponseWriter)(nil)
+	_ http.Hijacker       = (*recovererResponseWriter)(nil)
+)
+
+// recovererResponseWriter tracks whether the response header has been written or the connection hijacked.
+// Every other optional interface is reachable through Unwrap ([http.ResponseController]).
+type recovererResponseWriter struct {
+	w           http.ResponseWriter
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (rw *recovererResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+func (rw *recovererResponseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *recovererResponseWriter) WriteHeader(statusCode int) {
+	// informational headers do not commit the response.
+	if statusCode >= 200 || statusCode == http.StatusSwitchingProtocols {
+		rw.wroteHeader = true
+	}
+	rw.w.WriteHeader(statusCode)
+}
+
+func (rw *recovererResponseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.w.Write(p)
+}
+
+func (rw *recovererResponseWriter) Flush() {
+	rw.wroteHeader = true
+	_ = http.NewResponseController(rw.w).Flush()
+}
+
+func (rw *recovererResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.w).Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+	return conn, brw, err
+}
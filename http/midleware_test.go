@@ -3,7 +3,9 @@ package http
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -23,19 +25,19 @@ func TestRecoverer(t *testing.T) {
 		"panic string": {
 			Handler:         func(_ http.ResponseWriter, _ *http.Request) { panic("panic") },
 			ExpectedStatus:  http.StatusInternalServerError,
-			ExpectedBodyStr: ``,
+			ExpectedBodyStr: `{"type":"about:blank", "title":"Internal Server Error", "status":500}`,
 			ExpectedLogStr:  `{"level":"ERROR", "msg":"recovered from panic", "recover":"panic", "recover_type":"string"}`,
 		},
 		"panic error": {
 			Handler:         func(_ http.ResponseWriter, _ *http.Request) { panic(errors.New("error")) },
 			ExpectedStatus:  http.StatusInternalServerError,
-			ExpectedBodyStr: ``,
+			ExpectedBodyStr: `{"type":"about:blank", "title":"Internal Server Error", "status":500}`,
 			ExpectedLogStr:  `{"level":"ERROR", "msg":"recovered from panic", "recover":"error", "recover_type":"*errors.errorString"}`,
 		},
 		"http.ErrAbortHandler": {
 			Handler:         func(_ http.ResponseWriter, _ *http.Request) { panic(http.ErrAbortHandler) },
 			ExpectedStatus:  http.StatusInternalServerError,
-			ExpectedBodyStr: ``,
+			ExpectedBodyStr: `{"type":"about:blank", "title":"Internal Server Error", "status":500}`,
 			ExpectedLogStr:  ``,
 		},
 	}
@@ -73,6 +75,119 @@ func TestRecoverer(t *testing.T) {
 	}
 }
 
+func TestRecovererOptions(t *testing.T) {
+	var hooked []PanicInfo
+
+	logger, buf := testLogger()
+	handler := Recoverer(
+		logger,
+		WithRecovererStackTrace(true),
+		WithRecovererResponse(RecoverJSONResponse),
+		WithRecovererOnPanic(func(_ *http.Request, info PanicInfo) { hooked = append(hooked, info) }),
+	)(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) { panic("boom") }))
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil)
+	require.NoError(t, err)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500}`, rr.Body.String())
+
+	require.Len(t, hooked, 1)
+	assert.Equal(t, "boom", hooked[0].Value)
+	assert.Contains(t, string(hooked[0].Stack), "TestRecovererOptions")
+	assert.False(t, hooked[0].HeaderWritten)
+
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &logged))
+	assert.Equal(t, "boom", logged["recover"])
+	assert.Contains(t, logged["stack"], "runtime/debug.Stack")
+}
+
+func TestRecovererHeaderWritten(t *testing.T) {
+	hooked := make(chan PanicInfo, 1)
+	logger, buf := testLogger()
+
+	srv := httptest.NewServer(Recoverer(
+		logger,
+		WithRecovererOnPanic(func(_ *http.Request, info PanicInfo) { hooked <- info }),
+	)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		panic("boom")
+	})))
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the response is aborted, not completed.
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	info := <-hooked
+	assert.True(t, info.HeaderWritten)
+	assert.JSONEq(t, `{"level":"ERROR", "msg":"recovered from panic", "recover":"boom", "recover_type":"string", "header_written":true}`, buf.String())
+}
+
+func TestRecovererHijacked(t *testing.T) {
+	hooked := make(chan PanicInfo, 1)
+	logger, buf := testLogger()
+
+	srv := httptest.NewServer(Recoverer(
+		logger,
+		WithRecovererOnPanic(func(_ *http.Request, info PanicInfo) { hooked <- info }),
+	)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			panic(err)
+		}
+		_, _ = brw.WriteString("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n")
+		_ = brw.Flush()
+		_ = conn.Close()
+		panic("boom")
+	})))
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	info := <-hooked
+	assert.True(t, info.Hijacked)
+	assert.JSONEq(t, `{"level":"ERROR", "msg":"recovered from panic", "recover":"boom", "recover_type":"string", "hijacked":true}`, buf.String())
+}
+
+func TestRecovererAbortHandlerAfterHeader(t *testing.T) {
+	logger, buf := testLogger()
+	handler := Recoverer(logger)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic(http.ErrAbortHandler)
+	}))
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil)
+	require.NoError(t, err)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { handler.ServeHTTP(rr, req) })
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, 0, buf.Len())
+}
+
 func testLogger() (*slog.Logger, *bytes.Buffer) {
 	b := &bytes.Buffer{}
 	h := slog.NewJSONHandler(b, &slog.HandlerOptions{AddSource: false, Level: slog.LevelError, ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ContentTypeProblemJSON = "application/problem+json"
+
+	// maxProblemDetailsSize caps the problem details body that is decoded from a response.
+	maxProblemDetailsSize = 1 << 20
+)
+
+// ProblemDetails is an RFC 9457 problem details object. It implements error, so that a decoded one
+// ([DecodeProblemDetails]) can be returned and matched with errors.As.
+type ProblemDetails struct {
+	// Type is a URI reference that identifies the problem type. Empty means "about:blank".
+	Type string
+	// Title is a short human-readable summary of the problem type.
+	Title string
+	// Status is the http status code.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string
+	// Instance is a URI reference that identifies this occurrence of the problem.
+	Instance string
+	// Extensions are the additional members. They can not override the members above.
+	Extensions map[string]any
+}
+
+// NewProblemDetails returns an "about:blank" problem details of the given status, with the status text as title.
+func NewProblemDetails(status int, detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *ProblemDetails) Error() string {
+	msg := "problem: " + strconv.Itoa(p.Status)
+	if p.Title != "" {
+		msg += " " + p.Title
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+
+	return msg
+}
+
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	maps.Copy(m, p.Extensions)
+
+	setIfNotEmpty(m, "type", p.Type)
+	setIfNotEmpty(m, "title", p.Title)
+	setIfNotEmpty(m, "detail", p.Detail)
+	setIfNotEmpty(m, "instance", p.Instance)
+
+	if p.Status != 0 {
+		m["status"] = p.Status
+	} else {
+		delete(m, "status")
+	}
+
+	return json.Marshal(m)
+}
+
+func setIfNotEmpty(m map[string]any, key, value string) {
+	if value != "" {
+		m[key] = value
+	} else {
+		delete(m, key)
+	}
+}
+
+// UnmarshalJSON decodes a problem details object. As RFC 9457 requires, a member of the wrong type is ignored.
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	*p = ProblemDetails{}
+
+	for k, v := range m {
+		switch k {
+		case "type":
+			p.Type, _ = v.(string)
+		case "title":
+			p.Title, _ = v.(string)
+		case "detail":
+			p.Detail, _ = v.(string)
+		case "instance":
+			p.Instance, _ = v.(string)
+		case "status":
+			if f, is := v.(float64); is && f == float64(int(f)) {
+				p.Status = int(f)
+			}
+		default:
+			if p.Extensions == nil {
+				p.Extensions = map[string]any{}
+			}
+			p.Extensions[k] = v
+		}
+	}
+
+	return nil
+}
+
+// WriteProblemDetails writes the problem details as an application/problem+json response. A zero status is written as 500.
+func WriteProblemDetails(w http.ResponseWriter, p *ProblemDetails) error {
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblemJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+
+	_, err = w.Write(append(b, '\n'))
+
+	return err
+}
+
+// DecodeProblemDetails decodes the application/problem+json body (up to 1MiB) of the response. The body is consumed but
+// not closed. A missing status member is set from the response status code. [ErrNotProblemDetails] is returned when the
+// response is not a problem details one.
+func DecodeProblemDetails(resp *http.Response) (*ProblemDetails, error) {
+	if resp == nil || resp.Body == nil {
+		return nil, ErrNotProblemDetails
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != ContentTypeProblemJSON {
+		return nil, ErrNotProblemDetails
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxProblemDetailsSize))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ProblemDetails{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProblemDetails, err)
+	}
+
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+
+	return p, nil
+}
+
+var (
+	ErrNotProblemDetails     = errors.New("response is not application/problem+json")
+	ErrInvalidProblemDetails = errors.New("invalid problem details")
+)
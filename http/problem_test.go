@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetailsJSON(t *testing.T) {
+	tests := map[string]struct {
+		Problem      *ProblemDetails
+		ExpectedJSON string
+	}{
+		"standard members": {
+			Problem:      &ProblemDetails{Type: "https://example.test/probs/out-of-credit", Title: "Out of credit", Status: 403, Detail: "balance is 30", Instance: "/account/12345/msgs/abc"},
+			ExpectedJSON: `{"type":"https://example.test/probs/out-of-credit","title":"Out of credit","status":403,"detail":"balance is 30","instance":"/account/12345/msgs/abc"}`,
+		},
+		"extensions": {
+			Problem:      &ProblemDetails{Type: "about:blank", Status: 400, Extensions: map[string]any{"balance": 30, "accounts": []string{"a", "b"}}},
+			ExpectedJSON: `{"type":"about:blank","status":400,"balance":30,"accounts":["a","b"]}`,
+		},
+		"extensions do not override": {
+			Problem:      &ProblemDetails{Title: "Bad Request", Extensions: map[string]any{"title": "other", "status": 999, "detail": "other"}},
+			ExpectedJSON: `{"title":"Bad Request"}`,
+		},
+		"empty": {
+			Problem:      &ProblemDetails{},
+			ExpectedJSON: `{}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := json.Marshal(tc.Problem)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedJSON, string(b))
+
+			// a value (e.g. a non pointer field) is marshaled the same.
+			b, err = json.Marshal(struct{ Problem ProblemDetails }{Problem: *tc.Problem})
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"Problem":`+tc.ExpectedJSON+`}`, string(b))
+		})
+	}
+}
+
+func TestProblemDetailsUnmarshal(t *testing.T) {
+	tests := map[string]struct {
+		JSON        string
+		Expected    *ProblemDetails
+		ExpectedErr bool
+	}{
+		"standard members and extensions": {
+			JSON: `{"type":"https://example.test/probs/x","title":"X","status":409,"detail":"d","instance":"/i","balance":30}`,
+			Expected: &ProblemDetails{
+				Type: "https://example.test/probs/x", Title: "X", Status: 409, Detail: "d", Instance: "/i",
+				Extensions: map[string]any{"balance": float64(30)},
+			},
+		},
+		"wrong member types are ignored": {
+			JSON:     `{"type":1,"title":["x"],"status":"409","detail":null}`,
+			Expected: &ProblemDetails{},
+		},
+		"not an integer status": {
+			JSON:     `{"status":409.5}`,
+			Expected: &ProblemDetails{},
+		},
+		"not an object": {
+			JSON:        `["x"]`,
+			ExpectedErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := &ProblemDetails{}
+			err := json.Unmarshal([]byte(tc.JSON), got)
+			if tc.ExpectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, got)
+		})
+	}
+}
+
+func TestWriteProblemDetails(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Length", "3")
+
+	p := NewProblemDetails(http.StatusNotFound, "no such user")
+	p.Extensions = map[string]any{"user": "abc"}
+	require.NoError(t, WriteProblemDetails(rr, p))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, ContentTypeProblemJSON, rr.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	assert.Empty(t, rr.Header().Get("Content-Length"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"no such user","user":"abc"}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	require.NoError(t, WriteProblemDetails(rr, &ProblemDetails{Title: "oops"}))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestDecodeProblemDetails(t *testing.T) {
+	newResponse := func(status int, contentType, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("problem details", func(t *testing.T) {
+		p, err := DecodeProblemDetails(newResponse(403, "application/problem+json; charset=utf-8", `{"title":"Forbidden","detail":"nope","scope":"admin"}`))
+		require.NoError(t, err)
+		assert.Equal(t, &ProblemDetails{Title: "Forbidden", Status: 403, Detail: "nope", Extensions: map[string]any{"scope": "admin"}}, p)
+
+		// usable as a typed error.
+		err = fmt.Errorf("calling the api: %w", p)
+		var target *ProblemDetails
+		require.ErrorAs(t, err, &target)
+		assert.Equal(t, 403, target.Status)
+		assert.Equal(t, "calling the api: problem: 403 Forbidden: nope", err.Error())
+	})
+
+	t.Run("not problem details", func(t *testing.T) {
+		_, err := DecodeProblemDetails(newResponse(500, "application/json", `{"title":"x"}`))
+		require.ErrorIs(t, err, ErrNotProblemDetails)
+
+		_, err = DecodeProblemDetails(nil)
+		require.ErrorIs(t, err, ErrNotProblemDetails)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := DecodeProblemDetails(newResponse(500, ContentTypeProblemJSON, `{`))
+		require.ErrorIs(t, err, ErrInvalidProblemDetails)
+		assert.False(t, errors.Is(err, ErrNotProblemDetails))
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_ = WriteProblemDetails(w, NewProblemDetails(http.StatusConflict, "version mismatch"))
+		}))
+		defer srv.Close()
+
+		resp, err := srv.Client().Do(getRequest(t, srv.URL))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		p, err := DecodeProblemDetails(resp)
+		require.NoError(t, err)
+		assert.Equal(t, NewProblemDetails(http.StatusConflict, "version mismatch"), p)
+	})
+}
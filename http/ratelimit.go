@@ -0,0 +1,378 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultRateLimitIdleTimeout = 5 * time.Minute
+
+// NewRateLimitRoundTripper returns a [http.RoundTripper] that limits the requests per host (or per [WithRateLimitKey]) with a
+// token bucket ([WithRateLimit]) and a maximum of in-flight requests ([WithRateLimitMaxInFlight]). The requests block until they
+// are allowed or their context is done. By default the RateLimit-* / X-RateLimit-* response headers and the Retry-After of the
+// 429 and 503 responses adapt the limits to the ones of the server.
+func NewRateLimitRoundTripper(next http.RoundTripper, opts ...RateLimitOption) *RateLimitRoundTripper {
+	rt := &RateLimitRoundTripper{
+		next:        next,
+		headers:     true,
+		key:         func(req *http.Request) string { return req.URL.Host },
+		idleTimeout: DefaultRateLimitIdleTimeout,
+		limiters:    map[string]*limiter{},
+		now:         time.Now,
+	}
+
+	for _, o := range opts {
+		o(rt)
+	}
+
+	return rt
+}
+
+type RateLimitOption func(rt *RateLimitRoundTripper)
+
+// WithRateLimit sets the token bucket of every key: rate requests per second with bursts up to burst requests.
+// A rate <= 0 disables the rate limit.
+func WithRateLimit(rate float64, burst int) RateLimitOption {
+	return func(rt *RateLimitRoundTripper) {
+		rt.rate = max(rate, 0)
+		rt.burst = max(burst, 1)
+	}
+}
+
+// WithRateLimitMaxInFlight limits the concurrent requests of every key. A request is in flight until its response body is closed.
+// Zero means no limit.
+func WithRateLimitMaxInFlight(n int) RateLimitOption {
+	return func(rt *RateLimitRoundTripper) {
+		rt.maxInFlight = max(n, 0)
+	}
+}
+
+// WithRateLimitKey sets the function that selects the limits of a request. Default is the request host.
+// The limits of the keys that are not used for the idle timeout are dropped ([WithRateLimitIdleTimeout]).
+func WithRateLimitKey(fn func(req *http.Request) string) RateLimitOption {
+	return func(rt *RateLimitRoundTripper) {
+		rt.key = fn
+	}
+}
+
+// WithRateLimitIdleTimeout sets how long the limits of a key with no requests are kept (default [DefaultRateLimitIdleTimeout]).
+// The limits are dropped only when they are equal to new ones: no requests in flight, a full token bucket and no server
+// announced limit in effect. Zero keeps the limits forever, which is fine only for a small set of keys.
+func WithRateLimitIdleTimeout(d time.Duration) RateLimitOption {
+	return func(rt *RateLimitRoundTripper) {
+		rt.idleTimeout = max(d, 0)
+	}
+}
+
+// WithRateLimitHeaders sets whether the RateLimit-* / X-RateLimit-* and Retry-After response headers adapt the limits (default true).
+func WithRateLimitHeaders(enabled bool) RateLimitOption {
+	return func(rt *RateLimitRoundTripper) {
+		rt.headers = enabled
+	}
+}
+
+// WithRateLimitMaxWait fails the requests that would wait longer than d with [ErrRateLimited], instead of blocking.
+// Zero waits as long as the request context allows.
+func WithRateLimitMaxWait(d time.Duration) RateLimitOption {
+	return func(rt *RateLimitRoundTripper) {
+		rt.maxWait = max(d, 0)
+	}
+}
+
+type RateLimitRoundTripper struct {
+	next        http.RoundTripper
+	rate        float64
+	burst       int
+	maxInFlight int
+	headers     bool
+	maxWait     time.Duration
+	key         func(req *http.Request) string
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu           sync.Mutex
+	limiters     map[string]*limiter
+	lastEviction time.Time
+}
+
+func (rt *RateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := rt.limiter(rt.key(req))
+
+	release, err := rt.acquire(req.Context(), l)
+	if err != nil {
+		rt.releaseLimiter(l)
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	releaseInFlight := release
+	release = func() {
+		releaseInFlight()
+		rt.releaseLimiter(l)
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+
+	if rt.headers {
+		l.adapt(resp, rt.now())
+	}
+
+	resp.Body = &releaseOnCloseBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// limiter returns the limiter of the key, that is held until [RateLimitRoundTripper.releaseLimiter].
+func (rt *RateLimitRoundTripper) limiter(key string) *limiter {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	now := rt.now()
+	rt.evictIdle(now)
+
+	l, exists := rt.limiters[key]
+	if !exists {
+		l = &limiter{
+			rate:   rt.rate,
+			burst:  float64(rt.burst),
+			tokens: float64(rt.burst),
+			last:   now,
+		}
+		if rt.maxInFlight > 0 {
+			l.inFlight = make(chan struct{}, rt.maxInFlight)
+		}
+		rt.limiters[key] = l
+	}
+	l.refs++
+	l.lastUsed = now
+
+	return l
+}
+
+func (rt *RateLimitRoundTripper) releaseLimiter(l *limiter) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	l.refs--
+	l.lastUsed = rt.now()
+}
+
+// evictIdle drops the limiters that are not held, not used for the idle timeout and equal to new ones. It runs at most
+// once per idle timeout. rt.mu must be held.
+func (rt *RateLimitRoundTripper) evictIdle(now time.Time) {
+	if rt.idleTimeout <= 0 || now.Sub(rt.lastEviction) < rt.idleTimeout {
+		return
+	}
+	rt.lastEviction = now
+
+	for key, l := range rt.limiters {
+		if l.refs == 0 && now.Sub(l.lastUsed) >= rt.idleTimeout && l.idle(now) {
+			delete(rt.limiters, key)
+		}
+	}
+}
+
+// acquire blocks until the request is allowed, first by the in-flight limit and then by the rate limit.
+// The returned func releases the in-flight slot.
+func (rt *RateLimitRoundTripper) acquire(ctx context.Context, l *limiter) (func(), error) {
+	release := func() {}
+
+	if l.inFlight != nil {
+		var timeout <-chan time.Time
+		if rt.maxWait > 0 {
+			t := time.NewTimer(rt.maxWait)
+			defer t.Stop()
+			timeout = t.C
+		}
+
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout:
+			return nil, ErrRateLimited
+		}
+
+		release = func() { <-l.inFlight }
+	}
+
+	for {
+		wait := l.take(rt.now())
+		if wait <= 0 {
+			return release, nil
+		}
+
+		if err := rt.checkWait(ctx, wait); err != nil {
+			release()
+			return nil, err
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			release()
+			return nil, err
+		}
+	}
+}
+
+// checkWait fails early when the wait exceeds the max wait or the request context deadline.
+func (rt *RateLimitRoundTripper) checkWait(ctx context.Context, wait time.Duration) error {
+	if rt.maxWait > 0 && wait > rt.maxWait {
+		return ErrRateLimited
+	}
+
+	if deadline, exists := ctx.Deadline(); exists && rt.now().Add(wait).After(deadline) {
+		return errors.Join(ErrRateLimited, context.DeadlineExceeded)
+	}
+
+	return nil
+}
+
+// limiter is the token bucket, the in-flight semaphore and the server announced limits of a key.
+type limiter struct {
+	inFlight chan struct{}
+
+	// refs (the requests that hold the limiter) and lastUsed are guarded by the RateLimitRoundTripper mutex.
+	refs     int
+	lastUsed time.Time
+
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// blockedUntil is set from the response headers, when the server quota is exhausted.
+	blockedUntil time.Time
+	// remaining is the server announced remaining quota, valid until resetAt.
+	remaining int
+	resetAt   time.Time
+}
+
+// take takes a token and returns zero, or returns the wait until a token is available.
+func (l *limiter) take(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+
+	quota := now.Before(l.resetAt)
+	if quota && l.remaining == 0 {
+		return l.resetAt.Sub(now)
+	}
+
+	if l.rate > 0 {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+
+		if l.tokens < 1 {
+			return time.Duration(math.Ceil((1 - l.tokens) / l.rate * float64(time.Second)))
+		}
+		l.tokens--
+	}
+
+	if quota {
+		l.remaining--
+	}
+
+	return 0
+}
+
+// idle reports whether the limiter is equal to a new one: a full token bucket and no server announced limit in effect.
+func (l *limiter) idle(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.blockedUntil) || now.Before(l.resetAt) {
+		return false
+	}
+
+	return l.rate <= 0 || l.tokens+now.Sub(l.last).Seconds()*l.rate >= l.burst
+}
+
+// adapt updates the limiter from the rate limit headers of the response.
+func (l *limiter) adapt(resp *http.Response, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if d, exists := parseRetryAfter(resp.Header.Get("Retry-After"), now); exists {
+			l.blockedUntil = now.Add(d)
+			return
+		}
+	}
+
+	remaining, hasRemaining := rateLimitHeaderInt(resp.Header, "Remaining")
+	reset, hasReset := rateLimitReset(resp.Header, now)
+
+	switch {
+	case hasRemaining && hasReset:
+		l.remaining = remaining
+		l.resetAt = now.Add(reset)
+	case hasReset && resp.StatusCode == http.StatusTooManyRequests:
+		l.blockedUntil = now.Add(reset)
+	}
+}
+
+// rateLimitHeaderInt reads the RateLimit-<name> header, or the X-RateLimit-<name> one.
+func rateLimitHeaderInt(h http.Header, name string) (int, bool) {
+	v := h.Get("RateLimit-" + name)
+	if v == "" {
+		v = h.Get("X-RateLimit-" + name)
+	}
+
+	// "10, 10;w=1" (policies) style values, the first item is the effective one.
+	v, _, _ = strings.Cut(v, ",")
+	v, _, _ = strings.Cut(v, ";")
+
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// unixResetThreshold separates the reset values that are delta seconds from the ones that are unix timestamps (e.g. X-RateLimit-Reset of GitHub).
+const unixResetThreshold = 1_000_000_000
+
+// rateLimitReset returns the duration until the quota resets.
+func rateLimitReset(h http.Header, now time.Time) (time.Duration, bool) {
+	n, exists := rateLimitHeaderInt(h, "Reset")
+	if !exists {
+		return 0, false
+	}
+
+	if n >= unixResetThreshold {
+		return max(time.Unix(int64(n), 0).Sub(now), 0), true
+	}
+
+	return time.Duration(n) * time.Second, true
+}
+
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+var ErrRateLimited = errors.New("rate limit: wait exceeds the allowed time")
@@ -0,0 +1,274 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okRoundTripper() http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+}
+
+func getRequest(t *testing.T, url string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return req
+}
+
+func TestLimiterTake(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &limiter{rate: 2, burst: 2, tokens: 2, last: now}
+
+	assert.Zero(t, l.take(now))
+	assert.Zero(t, l.take(now))
+	assert.Equal(t, 500*time.Millisecond, l.take(now))
+
+	now = now.Add(250 * time.Millisecond)
+	assert.Equal(t, 250*time.Millisecond, l.take(now))
+
+	now = now.Add(250 * time.Millisecond)
+	assert.Zero(t, l.take(now))
+
+	// server quota.
+	l = &limiter{remaining: 1, resetAt: now.Add(10 * time.Second)}
+	assert.Zero(t, l.take(now))
+	assert.Equal(t, 10*time.Second, l.take(now))
+	assert.Zero(t, l.take(now.Add(10*time.Second)))
+
+	l = &limiter{blockedUntil: now.Add(time.Second)}
+	assert.Equal(t, time.Second, l.take(now))
+}
+
+func TestLimiterAdapt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		Status               int
+		Header               http.Header
+		ExpectedBlockedUntil time.Time
+		ExpectedRemaining    int
+		ExpectedResetAt      time.Time
+	}{
+		"ratelimit headers": {
+			Status:            http.StatusOK,
+			Header:            http.Header{"Ratelimit-Remaining": {"4"}, "Ratelimit-Reset": {"30"}},
+			ExpectedRemaining: 4,
+			ExpectedResetAt:   now.Add(30 * time.Second),
+		},
+		"x-ratelimit headers unix reset": {
+			Status:            http.StatusOK,
+			Header:            http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1735689660"}},
+			ExpectedRemaining: 0,
+			ExpectedResetAt:   now.Add(time.Minute),
+		},
+		"policies value": {
+			Status:            http.StatusOK,
+			Header:            http.Header{"Ratelimit-Remaining": {"7, 100;w=60"}, "Ratelimit-Reset": {"5"}},
+			ExpectedRemaining: 7,
+			ExpectedResetAt:   now.Add(5 * time.Second),
+		},
+		"retry after": {
+			Status:               http.StatusTooManyRequests,
+			Header:               http.Header{"Retry-After": {"3"}, "Ratelimit-Remaining": {"4"}, "Ratelimit-Reset": {"30"}},
+			ExpectedBlockedUntil: now.Add(3 * time.Second),
+		},
+		"retry after ignored on 200": {
+			Status: http.StatusOK,
+			Header: http.Header{"Retry-After": {"3"}},
+		},
+		"429 reset only": {
+			Status:               http.StatusTooManyRequests,
+			Header:               http.Header{"X-Ratelimit-Reset": {"2"}},
+			ExpectedBlockedUntil: now.Add(2 * time.Second),
+		},
+		"invalid": {
+			Status: http.StatusOK,
+			Header: http.Header{"Ratelimit-Remaining": {"-1"}, "Ratelimit-Reset": {"abc"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := &limiter{}
+			l.adapt(&http.Response{StatusCode: tc.Status, Header: tc.Header}, now)
+			assert.Equal(t, tc.ExpectedBlockedUntil, l.blockedUntil)
+			assert.Equal(t, tc.ExpectedRemaining, l.remaining)
+			assert.Equal(t, tc.ExpectedResetAt, l.resetAt)
+		})
+	}
+}
+
+func TestRateLimitRoundTripper(t *testing.T) {
+	rt := NewRateLimitRoundTripper(okRoundTripper(), WithRateLimit(50, 1))
+
+	start := time.Now()
+	for range 3 {
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	// the first one is the burst, two more wait 20ms each.
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+
+	// per key buckets.
+	assert.InDelta(t, 1.0, rt.limiter("b.test").tokens, 0)
+}
+
+func TestRateLimitRoundTripperContext(t *testing.T) {
+	rt := NewRateLimitRoundTripper(okRoundTripper(), WithRateLimit(0.1, 1))
+
+	resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// the wait (10s) exceeds the deadline, it fails without waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = rt.RoundTrip(getRequest(t, "http://a.test/").WithContext(ctx)) //nolint:bodyclose // no response.
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err = rt.RoundTrip(getRequest(t, "http://a.test/").WithContext(ctx)) //nolint:bodyclose // no response.
+	require.ErrorIs(t, err, context.Canceled)
+
+	rt = NewRateLimitRoundTripper(okRoundTripper(), WithRateLimit(0.1, 1), WithRateLimitMaxWait(time.Second))
+	resp, err = rt.RoundTrip(getRequest(t, "http://a.test/"))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	_, err = rt.RoundTrip(getRequest(t, "http://a.test/")) //nolint:bodyclose // no response.
+	require.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestRateLimitRoundTripperMaxInFlight(t *testing.T) {
+	var inFlight, peak atomic.Int32
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		inFlight.Add(-1)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	rt := NewRateLimitRoundTripper(next, WithRateLimitMaxInFlight(2), WithRateLimitKey(func(*http.Request) string { return "all" }))
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			host := "http://a.test/"
+			if i%2 == 0 {
+				host = "http://b.test/"
+			}
+			resp, err := rt.RoundTrip(getRequest(t, host))
+			if assert.NoError(t, err) {
+				assert.NoError(t, resp.Body.Close())
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+
+	// the slot is held until the body is closed.
+	rt = NewRateLimitRoundTripper(okRoundTripper(), WithRateLimitMaxInFlight(1), WithRateLimitMaxWait(10*time.Millisecond))
+	resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(getRequest(t, "http://a.test/")) //nolint:bodyclose // no response.
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, resp.Body.Close())
+	resp, err = rt.RoundTrip(getRequest(t, "http://a.test/"))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestRateLimitRoundTripperHeaders(t *testing.T) {
+	var calls atomic.Int32
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	rt := NewRateLimitRoundTripper(next, WithRateLimitMaxWait(100*time.Millisecond))
+	resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	_, err = rt.RoundTrip(getRequest(t, "http://a.test/")) //nolint:bodyclose // no response.
+	require.ErrorIs(t, err, ErrRateLimited)
+
+	calls.Store(0)
+	rt = NewRateLimitRoundTripper(next, WithRateLimitHeaders(false), WithRateLimitMaxWait(100*time.Millisecond))
+	for range 2 {
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+}
+
+func TestRateLimitRoundTripperIdleEviction(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/blocked" {
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3600"}}, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	rt := NewRateLimitRoundTripper(
+		next,
+		WithRateLimit(1, 1),
+		WithRateLimitIdleTimeout(time.Minute),
+		WithRateLimitKey(func(req *http.Request) string { return req.URL.Path }),
+	)
+	rt.now = func() time.Time { return now }
+
+	do := func(path string) *http.Response {
+		resp, err := rt.RoundTrip(getRequest(t, "http://a.test"+path))
+		require.NoError(t, err)
+		return resp
+	}
+
+	require.NoError(t, do("/1").Body.Close())
+	held := do("/2")
+	require.NoError(t, do("/blocked").Body.Close())
+	assert.Len(t, rt.limiters, 3)
+
+	// the in flight and the server blocked limiters are kept.
+	now = now.Add(time.Minute)
+	require.NoError(t, do("/3").Body.Close())
+	assert.Len(t, rt.limiters, 3)
+	assert.Contains(t, rt.limiters, "/2")
+	assert.Contains(t, rt.limiters, "/blocked")
+
+	require.NoError(t, held.Body.Close())
+	now = now.Add(time.Hour)
+	require.NoError(t, do("/3").Body.Close())
+	assert.Len(t, rt.limiters, 1)
+}
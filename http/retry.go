@@ -0,0 +1,300 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+	DefaultRetryJitter         = 0.5
+	DefaultRetryAfterMax       = 30 * time.Second
+)
+
+// RetryCondition decides, based on the response or the error of an attempt, if the request should be retried.
+type RetryCondition func(resp *http.Response, err error) bool
+
+// NewRetryRoundTripper returns a [http.RoundTripper] that retries the failed attempts with exponential backoff and jitter.
+// By default the idempotent requests (and the ones with an Idempotency-Key header) are retried on transport errors
+// and on 408, 429, 502, 503 and 504 responses, up to 3 attempts.
+func NewRetryRoundTripper(next http.RoundTripper, opts ...RetryOption) *RetryRoundTripper {
+	rt := &RetryRoundTripper{
+		next:           next,
+		maxAttempts:    DefaultRetryMaxAttempts,
+		initialBackoff: DefaultRetryInitialBackoff,
+		maxBackoff:     DefaultRetryMaxBackoff,
+		jitter:         DefaultRetryJitter,
+		retryAfterMax:  DefaultRetryAfterMax,
+		methods:        []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete},
+		statusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, o := range opts {
+		o(rt)
+	}
+
+	return rt
+}
+
+type RetryOption func(rt *RetryRoundTripper)
+
+// WithRetryMaxAttempts sets the maximum number of attempts, including the first one.
+func WithRetryMaxAttempts(n int) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.maxAttempts = max(n, 1)
+	}
+}
+
+// WithRetryBackoff sets the backoff of the first retry and the maximum backoff. The backoff doubles on every retry.
+func WithRetryBackoff(initial, maxBackoff time.Duration) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.initialBackoff = max(initial, 0)
+		rt.maxBackoff = max(maxBackoff, rt.initialBackoff)
+	}
+}
+
+// WithRetryJitter sets the fraction [0, 1] of the backoff that is randomized. Zero disables the jitter,
+// one randomizes the whole backoff (full jitter).
+func WithRetryJitter(fraction float64) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// WithRetryAfterMax sets the maximum Retry-After wait that is honored. A response with a longer Retry-After is returned
+// without retrying. Zero ignores the Retry-After header.
+func WithRetryAfterMax(d time.Duration) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.retryAfterMax = max(d, 0)
+	}
+}
+
+// WithRetryPerAttemptTimeout sets a timeout on every attempt. The timeout covers the response body read of the returned attempt too.
+func WithRetryPerAttemptTimeout(d time.Duration) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.perAttemptTimeout = max(d, 0)
+	}
+}
+
+// WithRetryMethods sets the request methods that are retried. Requests with an Idempotency-Key header are always retried.
+func WithRetryMethods(methods ...string) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.methods = methods
+	}
+}
+
+// WithRetryStatusCodes sets the response status codes that are retried.
+func WithRetryStatusCodes(codes ...int) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.statusCodes = codes
+	}
+}
+
+// WithRetryCondition replaces the default (transport error or retryable status code) retry condition.
+func WithRetryCondition(c RetryCondition) RetryOption {
+	return func(rt *RetryRoundTripper) {
+		rt.condition = c
+	}
+}
+
+type RetryRoundTripper struct {
+	next              http.RoundTripper
+	maxAttempts       int
+	initialBackoff    time.Duration
+	maxBackoff        time.Duration
+	jitter            float64
+	retryAfterMax     time.Duration
+	perAttemptTimeout time.Duration
+	methods           []string
+	statusCodes       []int
+	condition         RetryCondition
+}
+
+func (rt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	attempts := rt.maxAttempts
+	if !rt.retryable(req) {
+		attempts = 1
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq, cancel, err := rt.attemptRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := rt.next.RoundTrip(attemptReq)
+
+		if attempt+1 >= attempts || ctx.Err() != nil || !rt.shouldRetry(resp, err) {
+			return withCancel(resp, err, cancel)
+		}
+
+		wait, retry := rt.backoff(attempt, resp)
+		if !retry {
+			return withCancel(resp, err, cancel)
+		}
+
+		// discard the attempt, draining the body keeps the connection reusable.
+		_ = DrainAndCloseResponse(resp)
+		cancelAttempt(cancel)
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether the request can be retried: it has to be idempotent and its body (if any) replayable.
+func (rt *RetryRoundTripper) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return true
+	}
+
+	return slices.Contains(rt.methods, req.Method)
+}
+
+func (rt *RetryRoundTripper) shouldRetry(resp *http.Response, err error) bool {
+	if rt.condition != nil {
+		return rt.condition(resp, err)
+	}
+
+	if err != nil {
+		return true
+	}
+
+	return slices.Contains(rt.statusCodes, resp.StatusCode)
+}
+
+// attemptRequest returns the request of the given attempt, with the per attempt timeout and a replayed body for the retries.
+func (rt *RetryRoundTripper) attemptRequest(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(nil)
+	if rt.perAttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, rt.perAttemptTimeout)
+	}
+
+	r := req.Clone(ctx)
+
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			cancelAttempt(cancel)
+			return nil, nil, errors.Join(ErrRetryBodyReplay, err)
+		}
+		r.Body = body
+	}
+
+	return r, cancel, nil
+}
+
+// backoff returns the wait before the next attempt. Retry-After, when present, overrides the exponential backoff.
+// False is returned when Retry-After exceeds the maximum wait.
+func (rt *RetryRoundTripper) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if rt.retryAfterMax > 0 && resp != nil {
+		if d, exists := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); exists {
+			return d, d <= rt.retryAfterMax
+		}
+	}
+
+	d := rt.initialBackoff
+	for range attempt {
+		d *= 2
+		if d >= rt.maxBackoff {
+			break
+		}
+	}
+	d = min(d, rt.maxBackoff)
+
+	if rt.jitter > 0 && d > 0 {
+		jitter := time.Duration(rt.jitter * float64(d))
+		d = d - jitter + rand.N(jitter+1) //nolint:gosec // jitter does not need a secure random source.
+	}
+
+	return d, true
+}
+
+// parseRetryAfter parses the Retry-After header, either delay seconds or an http date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func cancelAttempt(cancel context.CancelFunc) {
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// withCancel ties the attempt context cancellation (if any) to the response body close.
+func withCancel(resp *http.Response, err error, cancel context.CancelFunc) (*http.Response, error) {
+	if cancel == nil {
+		return resp, err
+	}
+
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+var ErrRetryBodyReplay = errors.New("retry: failed to replay the request body")
@@ -0,0 +1,336 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (rt roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt(r)
+}
+
+// trackedBody records whether it was drained and closed.
+type trackedBody struct {
+	io.Reader
+	drained bool
+	closed  bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.drained = true
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+var errTransport = errors.New("transport error")
+
+type attemptResult struct {
+	Status     int
+	RetryAfter string
+	Err        error
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	tests := map[string]struct {
+		Method           string
+		Body             io.Reader
+		Header           http.Header
+		Options          []RetryOption
+		Results          []attemptResult
+		ExpectedAttempts int
+		ExpectedStatus   int
+		ExpectedError    error
+	}{
+		"success first attempt": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Status: http.StatusOK}},
+			ExpectedAttempts: 1,
+			ExpectedStatus:   http.StatusOK,
+		},
+		"retry on status then success": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Status: http.StatusServiceUnavailable}, {Status: http.StatusBadGateway}, {Status: http.StatusOK}},
+			ExpectedAttempts: 3,
+			ExpectedStatus:   http.StatusOK,
+		},
+		"retry on transport error": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Err: errTransport}, {Status: http.StatusOK}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusOK,
+		},
+		"attempts exhausted returns last response": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Status: http.StatusServiceUnavailable}, {Status: http.StatusServiceUnavailable}, {Status: http.StatusServiceUnavailable}},
+			ExpectedAttempts: 3,
+			ExpectedStatus:   http.StatusServiceUnavailable,
+		},
+		"attempts exhausted returns last error": {
+			Method:           http.MethodGet,
+			Options:          []RetryOption{WithRetryMaxAttempts(2)},
+			Results:          []attemptResult{{Err: errTransport}, {Err: errTransport}},
+			ExpectedAttempts: 2,
+			ExpectedError:    errTransport,
+		},
+		"not retryable status": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Status: http.StatusInternalServerError}},
+			ExpectedAttempts: 1,
+			ExpectedStatus:   http.StatusInternalServerError,
+		},
+		"post is not retried": {
+			Method:           http.MethodPost,
+			Body:             strings.NewReader("payload"),
+			Results:          []attemptResult{{Status: http.StatusServiceUnavailable}},
+			ExpectedAttempts: 1,
+			ExpectedStatus:   http.StatusServiceUnavailable,
+		},
+		"post with idempotency key": {
+			Method:           http.MethodPost,
+			Body:             strings.NewReader("payload"),
+			Header:           http.Header{"Idempotency-Key": []string{"abc"}},
+			Results:          []attemptResult{{Status: http.StatusServiceUnavailable}, {Status: http.StatusCreated}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusCreated,
+		},
+		"custom methods": {
+			Method:           http.MethodPatch,
+			Options:          []RetryOption{WithRetryMethods(http.MethodPatch)},
+			Results:          []attemptResult{{Status: http.StatusServiceUnavailable}, {Status: http.StatusOK}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusOK,
+		},
+		"custom status codes": {
+			Method:           http.MethodGet,
+			Options:          []RetryOption{WithRetryStatusCodes(http.StatusInternalServerError)},
+			Results:          []attemptResult{{Status: http.StatusInternalServerError}, {Status: http.StatusOK}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusOK,
+		},
+		"custom condition": {
+			Method: http.MethodGet,
+			Options: []RetryOption{WithRetryCondition(func(resp *http.Response, err error) bool {
+				return err == nil && resp.StatusCode == http.StatusConflict
+			})},
+			Results:          []attemptResult{{Status: http.StatusConflict}, {Status: http.StatusServiceUnavailable}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusServiceUnavailable,
+		},
+		"retry after within max": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Status: http.StatusTooManyRequests, RetryAfter: "0"}, {Status: http.StatusOK}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusOK,
+		},
+		"retry after exceeds max": {
+			Method:           http.MethodGet,
+			Results:          []attemptResult{{Status: http.StatusTooManyRequests, RetryAfter: "120"}},
+			ExpectedAttempts: 1,
+			ExpectedStatus:   http.StatusTooManyRequests,
+		},
+		"retry after ignored": {
+			Method:           http.MethodGet,
+			Options:          []RetryOption{WithRetryAfterMax(0)},
+			Results:          []attemptResult{{Status: http.StatusTooManyRequests, RetryAfter: "120"}, {Status: http.StatusOK}},
+			ExpectedAttempts: 2,
+			ExpectedStatus:   http.StatusOK,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var bodies []*trackedBody
+			var sentBodies []string
+
+			attempts := 0
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				r := tc.Results[attempts]
+				attempts++
+
+				if req.Body != nil {
+					b, err := io.ReadAll(req.Body)
+					require.NoError(t, err)
+					sentBodies = append(sentBodies, string(b))
+				}
+
+				if r.Err != nil {
+					return nil, r.Err
+				}
+
+				body := &trackedBody{Reader: strings.NewReader("response body")}
+				bodies = append(bodies, body)
+				resp := &http.Response{StatusCode: r.Status, Header: http.Header{}, Body: body, Request: req}
+				if r.RetryAfter != "" {
+					resp.Header.Set("Retry-After", r.RetryAfter)
+				}
+				return resp, nil
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), tc.Method, "https://domain.test/", tc.Body)
+			require.NoError(t, err)
+			for k, v := range tc.Header {
+				req.Header[k] = v
+			}
+
+			opts := append([]RetryOption{WithRetryBackoff(time.Millisecond, 5*time.Millisecond)}, tc.Options...)
+			resp, err := NewRetryRoundTripper(next, opts...).RoundTrip(req)
+
+			assert.Equal(t, tc.ExpectedAttempts, attempts)
+
+			if tc.ExpectedError != nil {
+				require.ErrorIs(t, err, tc.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+
+			// every discarded attempt is drained and closed, the returned one is left to the caller.
+			for i, b := range bodies {
+				if i == len(bodies)-1 {
+					assert.False(t, b.closed)
+					continue
+				}
+				assert.True(t, b.drained)
+				assert.True(t, b.closed)
+			}
+
+			// the body is replayed on every attempt.
+			if tc.Body != nil {
+				for _, b := range sentBodies {
+					assert.Equal(t, "payload", b)
+				}
+			}
+
+			require.NoError(t, DrainAndCloseResponse(resp))
+		})
+	}
+}
+
+func TestRetryRoundTripperPerAttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	rt := NewRetryRoundTripper(srv.Client().Transport, WithRetryPerAttemptTimeout(50*time.Millisecond), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	// the attempt context is alive until the body is closed.
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryRoundTripperContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		cancel()
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://domain.test/", nil)
+	require.NoError(t, err)
+
+	resp, err := NewRetryRoundTripper(next, WithRetryBackoff(time.Hour, time.Hour)).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryRoundTripperBodyWithoutGetBody(t *testing.T) {
+	attempts := 0
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, "https://domain.test/", io.MultiReader(bytes.NewReader([]byte("payload"))))
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+
+	resp, err := NewRetryRoundTripper(next).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rt := NewRetryRoundTripper(nil, WithRetryBackoff(100*time.Millisecond, time.Second), WithRetryJitter(0))
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, e := range expected {
+		d, retry := rt.backoff(attempt, nil)
+		assert.True(t, retry)
+		assert.Equal(t, e, d)
+	}
+
+	rt = NewRetryRoundTripper(nil, WithRetryBackoff(100*time.Millisecond, time.Second), WithRetryJitter(0.5))
+	for range 100 {
+		d, _ := rt.backoff(1, nil)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 200*time.Millisecond)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		Value          string
+		ExpectedWait   time.Duration
+		ExpectedExists bool
+	}{
+		"empty":       {Value: "", ExpectedExists: false},
+		"seconds":     {Value: "3", ExpectedWait: 3 * time.Second, ExpectedExists: true},
+		"negative":    {Value: "-3", ExpectedWait: 0, ExpectedExists: true},
+		"http date":   {Value: now.Add(10 * time.Second).Format(http.TimeFormat), ExpectedWait: 10 * time.Second, ExpectedExists: true},
+		"date passed": {Value: now.Add(-time.Minute).Format(http.TimeFormat), ExpectedWait: 0, ExpectedExists: true},
+		"invalid":     {Value: "soon", ExpectedExists: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, exists := parseRetryAfter(tc.Value, now)
+			assert.Equal(t, tc.ExpectedExists, exists)
+			assert.Equal(t, tc.ExpectedWait, d)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const DefaultStatusErrorBodyLimit = 512
+
+// StatusError is the error of a response with an unexpected status code. It is returned by [CheckResponse] and the
+// [NewStatusErrorRoundTripper]. When the body is a (not truncated) problem details one, it is decoded and unwrapped,
+// so errors.As(err, &problem) works too.
+type StatusError struct {
+	Method string
+	// URL is the request url without the user info, the query and the fragment.
+	URL        string
+	StatusCode int
+	Status     string
+	// Header holds only the selected response headers ([WithStatusErrorHeaders]).
+	Header http.Header
+	// Body is a bounded ([WithStatusErrorBodyLimit]) snippet of the response body, with the control characters and the
+	// invalid UTF-8 replaced. It is empty for the non textual content types.
+	Body string
+	// Truncated reports whether the body was longer than the snippet.
+	Truncated bool
+	// Problem is the decoded problem details body, if any.
+	Problem *ProblemDetails
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: unexpected status %s", e.Method, e.URL, e.Status)
+	if e.Body != "" {
+		msg += ": " + e.Body
+		if e.Truncated {
+			msg += "..."
+		}
+	}
+
+	return msg
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.Problem == nil {
+		return nil
+	}
+
+	return e.Problem
+}
+
+type StatusErrorOption func(c *statusErrorConfig)
+
+type statusErrorConfig struct {
+	expected  []int
+	headers   []string
+	bodyLimit int
+}
+
+func newStatusErrorConfig(opts []StatusErrorOption) *statusErrorConfig {
+	c := &statusErrorConfig{
+		headers:   []string{"Content-Type", "Retry-After", "X-Request-Id"},
+		bodyLimit: DefaultStatusErrorBodyLimit,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// WithExpectedStatus sets the expected status codes. By default every 2xx status is expected.
+func WithExpectedStatus(codes ...int) StatusErrorOption {
+	return func(c *statusErrorConfig) {
+		c.expected = codes
+	}
+}
+
+// WithStatusErrorHeaders sets the response headers that are kept in the error (default Content-Type, Retry-After and X-Request-Id).
+func WithStatusErrorHeaders(names ...string) StatusErrorOption {
+	return func(c *statusErrorConfig) {
+		c.headers = names
+	}
+}
+
+// WithStatusErrorBodyLimit sets the maximum bytes of the body snippet (default 512). Zero disables the snippet.
+func WithStatusErrorBodyLimit(n int) StatusErrorOption {
+	return func(c *statusErrorConfig) {
+		c.bodyLimit = max(n, 0)
+	}
+}
+
+func (c *statusErrorConfig) expectedStatus(code int) bool {
+	if len(c.expected) == 0 {
+		return code >= 200 && code <= 299
+	}
+
+	return slices.Contains(c.expected, code)
+}
+
+// CheckResponse returns nil when the response status code is the expected one. Otherwise it drains and closes the
+// response body (with [DrainAndCloseBody]) and returns a [*StatusError].
+//
+//	resp, err := client.Do(req)
+//	if err != nil {
+//		return err
+//	}
+//	if err := CheckResponse(resp); err != nil {
+//		return err
+//	}
+func CheckResponse(resp *http.Response, opts ...StatusErrorOption) error {
+	return newStatusErrorConfig(opts).check(resp)
+}
+
+func (c *statusErrorConfig) check(resp *http.Response) error {
+	if c.expectedStatus(resp.StatusCode) {
+		return nil
+	}
+
+	e := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     http.Header{},
+	}
+
+	if e.Status == "" {
+		e.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	if req := resp.Request; req != nil {
+		e.Method = req.Method
+		if req.URL != nil {
+			u := *req.URL
+			u.User, u.RawQuery, u.ForceQuery, u.Fragment, u.RawFragment = nil, "", false, "", ""
+			e.URL = u.String()
+		}
+	}
+
+	for _, name := range c.headers {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			e.Header[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if c.bodyLimit > 0 && resp.Body != nil && textualMediaType(mediaType) {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, int64(c.bodyLimit)+1))
+		if len(b) > c.bodyLimit {
+			b, e.Truncated = b[:c.bodyLimit], true
+		}
+
+		if mediaType == ContentTypeProblemJSON && !e.Truncated {
+			p := &ProblemDetails{}
+			if err := json.Unmarshal(b, p); err == nil {
+				if p.Status == 0 {
+					p.Status = resp.StatusCode
+				}
+				e.Problem = p
+			}
+		}
+
+		e.Body = sanitizeSnippet(b)
+	}
+
+	_ = DrainAndCloseBody(resp.Body)
+
+	return e
+}
+
+// NewStatusErrorRoundTripper returns a [http.RoundTripper] that converts the responses with an unexpected status code
+// into a [*StatusError] (wrapped by the [http.Client] in a *url.Error). The body of those responses is drained and closed.
+// By default the 1xx and 3xx responses pass through, so the client can follow the redirects and return the 304s.
+func NewStatusErrorRoundTripper(next http.RoundTripper, opts ...StatusErrorOption) *StatusErrorRoundTripper {
+	return &StatusErrorRoundTripper{
+		next:   next,
+		config: newStatusErrorConfig(opts),
+	}
+}
+
+type StatusErrorRoundTripper struct {
+	next   http.RoundTripper
+	config *statusErrorConfig
+}
+
+func (rt *StatusErrorRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	// the informational and the redirect responses are left to the client (redirect following, 304 revalidation),
+	// unless the expected status codes are explicitly set.
+	if len(rt.config.expected) == 0 && (resp.StatusCode < 200 || (resp.StatusCode >= 300 && resp.StatusCode <= 399)) {
+		return resp, nil
+	}
+
+	if err := rt.config.check(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func textualMediaType(mediaType string) bool {
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded", "application/x-ndjson":
+		return true
+	default:
+		return false
+	}
+}
+
+// sanitizeSnippet replaces the invalid UTF-8 (including a rune cut by the limit) and the control characters with spaces,
+// collapses the consecutive whitespace and trims it.
+func sanitizeSnippet(b []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(b))
+
+	space := false
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+
+		if r == utf8.RuneError || unicode.IsControl(r) || unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+
+		if space && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}